@@ -13,7 +13,6 @@ import (
 	"time"
 
 	"github.com/docker/cli/cli/connhelper"
-	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/client"
 )
 
@@ -137,15 +136,13 @@ func (c *Config) NewClient() (*client.Client, error) {
 	)
 }
 
-// Data structure for holding data that we fetch from Docker.
-type Data struct {
-	DockerImages map[string]*types.ImageSummary
-}
-
 // ProviderConfig for the custom registry provider
 type ProviderConfig struct {
 	DockerClient *client.Client
 	AuthConfigs  *AuthConfigs
+	ImagePuller  *imagePuller
+	// RegistryTokens caches the bearer tokens of registries
+	RegistryTokens *registryTokenCache
 }
 
 // The registry address can be referenced in various places (registry auth, docker config file, image name)
@@ -0,0 +1,194 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// dockerContainerDataSourceAttributes are the attributes of the container
+// resource which the data source exposes as well
+var dockerContainerDataSourceAttributes = []string{
+	"exit_code",
+	"env",
+	"labels",
+	"mounts",
+	"volumes",
+	"ports",
+	"network_data",
+}
+
+func dataSourceDockerContainer() *schema.Resource {
+	dockerContainer := &schema.Resource{
+		Read: dataSourceDockerContainerRead,
+
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Type:        schema.TypeString,
+				Description: "Name of the container",
+				Optional:    true,
+				Computed:    true,
+			},
+
+			"id": {
+				Type:        schema.TypeString,
+				Description: "ID or ID prefix of the container",
+				Optional:    true,
+				Computed:    true,
+			},
+
+			"label_filters": {
+				Type:        schema.TypeMap,
+				Description: "Labels the container has to have, with the given values unless they are empty",
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+
+			"image": {
+				Type:        schema.TypeString,
+				Description: "Name of the image the container was created from",
+				Computed:    true,
+			},
+
+			"image_id": {
+				Type:        schema.TypeString,
+				Description: "ID of the image the container was created from",
+				Computed:    true,
+			},
+
+			"state": {
+				Type:        schema.TypeString,
+				Description: "State of the container, e.g. running or exited",
+				Computed:    true,
+			},
+
+			"health_status": {
+				Type:        schema.TypeString,
+				Description: "Health of the container, empty without a healthcheck",
+				Computed:    true,
+			},
+		},
+	}
+
+	resourceSchema := resourceDockerContainer().Schema
+	for _, attribute := range dockerContainerDataSourceAttributes {
+		dockerContainer.Schema[attribute] = computedSchema(resourceSchema[attribute])
+	}
+	return dockerContainer
+}
+
+func dataSourceDockerContainerRead(d *schema.ResourceData, meta interface{}) error {
+	name, nameOk := d.GetOk("name")
+	id, idOk := d.GetOk("id")
+	labelFilters, labelFiltersOk := d.GetOk("label_filters")
+
+	if !nameOk && !idOk && !labelFiltersOk {
+		return fmt.Errorf("One of id, name or label_filters must be assigned")
+	}
+
+	filter := filters.NewArgs()
+	if nameOk {
+		// the name filter matches parts of the names, which start with a slash
+		filter.Add("name", "^/?"+regexp.QuoteMeta(name.(string))+"$")
+	}
+	if idOk {
+		filter.Add("id", id.(string))
+	}
+	for label, value := range labelFilters.(map[string]interface{}) {
+		if value.(string) == "" {
+			filter.Add("label", label)
+		} else {
+			filter.Add("label", label+"="+value.(string))
+		}
+	}
+
+	client := meta.(*ProviderConfig).DockerClient
+	apiContainers, err := client.ContainerList(context.Background(), types.ContainerListOptions{All: true, Filters: filter})
+	if err != nil {
+		return fmt.Errorf("Error fetching container information from Docker: %s", err)
+	}
+	if len(apiContainers) == 0 {
+		return fmt.Errorf("Could not find docker container")
+	}
+	if len(apiContainers) > 1 {
+		return fmt.Errorf("Found %d docker containers, the filters have to match exactly one", len(apiContainers))
+	}
+
+	container, err := client.ContainerInspect(context.Background(), apiContainers[0].ID)
+	if err != nil {
+		return fmt.Errorf("Error inspecting container %s: %s", apiContainers[0].ID, err)
+	}
+
+	d.SetId(container.ID)
+	d.Set("id", container.ID)
+	d.Set("name", strings.TrimPrefix(container.Name, "/"))
+	d.Set("image_id", container.Image)
+
+	if container.State != nil {
+		d.Set("state", container.State.Status)
+		d.Set("exit_code", container.State.ExitCode)
+		if container.State.Health != nil {
+			d.Set("health_status", container.State.Health.Status)
+		}
+	}
+
+	// The env and labels are read including the ones inherited from the image
+	if container.Config != nil {
+		d.Set("image", container.Config.Image)
+		if err := d.Set("env", flattenContainerEnv(container.Config.Env, nil, nil)); err != nil {
+			log.Printf("[WARN] failed to set env from API: %s", err)
+		}
+		if err := d.Set("labels", flattenContainerLabels(container.Config.Labels, nil, nil)); err != nil {
+			log.Printf("[WARN] failed to set labels from API: %s", err)
+		}
+	}
+
+	if container.HostConfig != nil && container.Config != nil {
+		if err := d.Set("mounts", flattenContainerMounts(container.HostConfig.Mounts)); err != nil {
+			log.Printf("[WARN] failed to set mounts from API: %s", err)
+		}
+		if err := d.Set("volumes", flattenContainerVolumes(container.HostConfig, container.Config.Volumes, nil, nil)); err != nil {
+			log.Printf("[WARN] failed to set volumes from API: %s", err)
+		}
+	}
+
+	if container.NetworkSettings != nil {
+		if err := d.Set("ports", flattenContainerPorts(container.NetworkSettings.Ports)); err != nil {
+			log.Printf("[WARN] failed to set ports from API: %s", err)
+		}
+		if err := d.Set("network_data", flattenContainerNetworks(container.NetworkSettings)); err != nil {
+			log.Printf("[WARN] failed to set network settings from API: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// computedSchema returns a computed copy of the schema of a resource
+// attribute, without the settings which only apply to arguments
+func computedSchema(s *schema.Schema) *schema.Schema {
+	computed := &schema.Schema{
+		Type:        s.Type,
+		Description: s.Description,
+		Computed:    true,
+		Set:         s.Set,
+	}
+
+	switch elem := s.Elem.(type) {
+	case *schema.Resource:
+		elemSchema := make(map[string]*schema.Schema, len(elem.Schema))
+		for attribute, attributeSchema := range elem.Schema {
+			elemSchema[attribute] = computedSchema(attributeSchema)
+		}
+		computed.Elem = &schema.Resource{Schema: elemSchema}
+	case *schema.Schema:
+		computed.Elem = &schema.Schema{Type: elem.Type}
+	}
+	return computed
+}
@@ -0,0 +1,103 @@
+package docker
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func TestAccDockerContainerDataSource_basic(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDockerContainerDataSourceConfig,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttrPair("data.docker_container.by_name", "id", "docker_container.foo", "id"),
+					resource.TestCheckResourceAttrPair("data.docker_container.by_labels", "id", "docker_container.foo", "id"),
+					resource.TestCheckResourceAttr("data.docker_container.by_name", "name", "tf-test-datasource"),
+					resource.TestCheckResourceAttr("data.docker_container.by_name", "image", "nginx:latest"),
+					resource.TestCheckResourceAttrPair("data.docker_container.by_name", "image_id", "docker_image.foo", "latest"),
+					resource.TestCheckResourceAttr("data.docker_container.by_name", "state", "running"),
+					resource.TestCheckResourceAttr("data.docker_container.by_name", "exit_code", "0"),
+					resource.TestCheckResourceAttr("data.docker_container.by_name", "labels.team", "sidecars"),
+					resource.TestCheckResourceAttr("data.docker_container.by_name", "ports.#", "1"),
+					resource.TestCheckResourceAttr("data.docker_container.by_name", "ports.0.internal", "80"),
+					resource.TestCheckResourceAttr("data.docker_container.by_name", "ports.0.external", "32789"),
+					resource.TestCheckResourceAttr("data.docker_container.by_name", "network_data.#", "1"),
+					resource.TestCheckResourceAttrPair("data.docker_container.by_name", "network_data.0.ip_address", "docker_container.foo", "network_data.0.ip_address"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccDockerContainerDataSource_notFound(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccDockerContainerDataSourceNotFoundConfig,
+				ExpectError: regexp.MustCompile(`Could not find docker container`),
+			},
+		},
+	})
+}
+
+func TestComputedSchema(t *testing.T) {
+	for _, attribute := range dockerContainerDataSourceAttributes {
+		s := computedSchema(resourceDockerContainer().Schema[attribute])
+		if !s.Computed || s.Optional || s.Required || s.ForceNew {
+			t.Errorf("expected attribute %s to be computed only, got %+v", attribute, s)
+		}
+		if elem, ok := s.Elem.(*schema.Resource); ok {
+			for nested, nestedSchema := range elem.Schema {
+				if !nestedSchema.Computed || nestedSchema.Default != nil || nestedSchema.ValidateFunc != nil {
+					t.Errorf("expected attribute %s.%s to be computed only, got %+v", attribute, nested, nestedSchema)
+				}
+			}
+		}
+	}
+}
+
+const testAccDockerContainerDataSourceConfig = `
+resource "docker_image" "foo" {
+	name = "nginx:latest"
+}
+
+resource "docker_container" "foo" {
+	name  = "tf-test-datasource"
+	image = "${docker_image.foo.latest}"
+
+	labels = {
+		team = "sidecars"
+		tier = "proxy"
+	}
+
+	ports {
+		internal = 80
+		external = 32789
+	}
+}
+
+data "docker_container" "by_name" {
+	name = "${docker_container.foo.name}"
+}
+
+data "docker_container" "by_labels" {
+	label_filters = {
+		team = "sidecars"
+		tier = "${docker_container.foo.labels["tier"]}"
+	}
+}
+`
+
+const testAccDockerContainerDataSourceNotFoundConfig = `
+data "docker_container" "foo" {
+	name = "tf-test-datasource-missing"
+}
+`
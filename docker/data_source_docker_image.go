@@ -0,0 +1,61 @@
+package docker
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// dockerImageMetadataAttributes are the computed attributes of the image
+// resource which the data source exposes as well
+var dockerImageMetadataAttributes = []string{
+	"repo_digests",
+	"repo_tags",
+	"size",
+	"created",
+	"architecture",
+	"os",
+	"labels",
+	"env",
+	"entrypoint",
+	"cmd",
+	"exposed_ports",
+	"healthcheck",
+}
+
+func dataSourceDockerImage() *schema.Resource {
+	imageSchema := map[string]*schema.Schema{
+		"name": {
+			Type:        schema.TypeString,
+			Description: "Name, ID or digest reference of the local image",
+			Required:    true,
+		},
+	}
+	resourceSchema := resourceDockerImage().Schema
+	for _, attribute := range dockerImageMetadataAttributes {
+		imageSchema[attribute] = resourceSchema[attribute]
+	}
+
+	return &schema.Resource{
+		Read:   dataSourceDockerImageRead,
+		Schema: imageSchema,
+	}
+}
+
+func dataSourceDockerImageRead(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*ProviderConfig).DockerClient
+	imageName := d.Get("name").(string)
+
+	foundImage, err := searchLocalImage(client, imageName)
+	if err != nil {
+		return err
+	}
+	if foundImage == nil {
+		return fmt.Errorf("Image %s does not exist on the Docker host, the data source does not pull images", imageName)
+	}
+
+	d.SetId(foundImage.ID)
+	setImageMetadata(d, foundImage)
+
+	return nil
+}
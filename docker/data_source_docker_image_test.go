@@ -0,0 +1,60 @@
+package docker
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+)
+
+func TestAccDockerImageDataSource_basic(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDockerImageDataSourceLocalConfig,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttrPair("data.docker_image.by_id", "id", "docker_image.foo", "latest"),
+					resource.TestCheckResourceAttr("data.docker_image.by_id", "repo_tags.0", "alpine:3.1"),
+					resource.TestCheckResourceAttr("data.docker_image.by_id", "os", "linux"),
+					resource.TestCheckResourceAttr("data.docker_image.by_id", "cmd.0", "/bin/sh"),
+					resource.TestCheckResourceAttrPair("data.docker_image.by_digest", "id", "docker_image.foo", "latest"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccDockerImageDataSource_missing(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccDockerImageDataSourceMissingConfig,
+				ExpectError: regexp.MustCompile(`does not exist on the Docker host`),
+			},
+		},
+	})
+}
+
+const testAccDockerImageDataSourceLocalConfig = `
+resource "docker_image" "foo" {
+	name = "alpine:3.1"
+}
+
+data "docker_image" "by_id" {
+	name = "${docker_image.foo.latest}"
+}
+
+data "docker_image" "by_digest" {
+	name = "${docker_image.foo.repo_digests[0]}"
+}
+`
+
+const testAccDockerImageDataSourceMissingConfig = `
+data "docker_image" "missing" {
+	name = "tftest-does-not-exist:never"
+}
+`
@@ -1,21 +1,36 @@
 package docker
 
 import (
-	"crypto/tls"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"log"
 	"net/http"
-	"net/url"
-	"os"
-	"strconv"
 	"strings"
 
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
 	"github.com/hashicorp/terraform/helper/schema"
 )
 
+// dockerRegistryImageConfigAttributes are the computed attributes of the
+// image resource which the data source fills in from the config of the image
+var dockerRegistryImageConfigAttributes = []string{
+	"created",
+	"labels",
+	"env",
+	"entrypoint",
+	"cmd",
+	"exposed_ports",
+}
+
 func dataSourceDockerRegistryImage() *schema.Resource {
-	return &schema.Resource{
+	registryImage := &schema.Resource{
 		Read: dataSourceDockerRegistryImageRead,
 
 		Schema: map[string]*schema.Schema{
@@ -24,17 +39,148 @@ func dataSourceDockerRegistryImage() *schema.Resource {
 				Optional: true,
 			},
 
+			"platform": {
+				Type:         schema.TypeString,
+				Description:  "Platform to resolve multi-platform images to, e.g. linux/arm64",
+				Optional:     true,
+				ValidateFunc: validateStringMatchesPattern(platformPattern),
+			},
+
+			"signature_public_key": {
+				Type:         schema.TypeString,
+				Description:  "PEM-encoded public key the image has to be signed with in the registry",
+				Optional:     true,
+				ValidateFunc: validateStringIsSignaturePublicKey(),
+			},
+
 			"sha256_digest": {
 				Type:     schema.TypeString,
 				Computed: true,
 			},
+
+			"platforms": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+
+			"fetch_config": {
+				Type:        schema.TypeBool,
+				Description: "Fetch the config of the image to fill in its labels, env, layers etc.",
+				Optional:    true,
+			},
+
+			"layers": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"digest": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"size": {
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
+						"media_type": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+					},
+				},
+			},
 		},
 	}
+
+	resourceSchema := resourceDockerImage().Schema
+	for _, attribute := range dockerRegistryImageConfigAttributes {
+		registryImage.Schema[attribute] = resourceSchema[attribute]
+	}
+	return registryImage
 }
 
 func dataSourceDockerRegistryImageRead(d *schema.ResourceData, meta interface{}) error {
-	pullOpts := parseImageOptions(d.Get("name").(string))
-	authConfig := meta.(*ProviderConfig).AuthConfigs
+	name := d.Get("name").(string)
+	if publicKey := d.Get("signature_public_key").(string); publicKey != "" {
+		verifiedDigest, err := verifyImageSignature(name, publicKey, meta.(*ProviderConfig))
+		if err != nil {
+			return fmt.Errorf("Signature verification of image %s failed: %s", name, err)
+		}
+		// the image is read by the verified digest, in case the tag is moved
+		// in the meantime
+		name = parseImageOptions(name).Repository + "@" + verifiedDigest
+	}
+
+	digest, platforms, err := getRegistryImageDigest(name, d.Get("platform").(string), meta.(*ProviderConfig))
+	if err != nil {
+		return fmt.Errorf("Got error when attempting to fetch image version from registry: %s", err)
+	}
+
+	d.SetId(digest)
+	d.Set("sha256_digest", digest)
+	d.Set("platforms", platforms)
+
+	if !d.Get("fetch_config").(bool) {
+		return nil
+	}
+
+	platform := d.Get("platform").(string)
+	if platform == "" {
+		platform = dockerDaemonPlatform(meta.(*ProviderConfig))
+	}
+
+	// the config is read by the digest resolved above, in case the tag is
+	// moved in the meantime
+	name = parseImageOptions(name).Repository + "@" + digest
+	manifest, imageConfig, err := getRegistryImageConfig(name, platform, meta.(*ProviderConfig))
+	if err != nil {
+		return fmt.Errorf("Got error when attempting to fetch image config from registry: %s", err)
+	}
+
+	d.Set("created", imageConfig.Created)
+	config := imageConfig.Config
+	if config == nil {
+		config = &container.Config{}
+	}
+	d.Set("labels", mapStringStringToMapStringInterface(config.Labels))
+	d.Set("env", config.Env)
+	d.Set("entrypoint", []string(config.Entrypoint))
+	d.Set("cmd", []string(config.Cmd))
+	if err := d.Set("exposed_ports", flattenImageExposedPorts(config.ExposedPorts)); err != nil {
+		log.Printf("[WARN] failed to set exposed ports from registry: %s", err)
+	}
+	if err := d.Set("layers", flattenRegistryImageLayers(manifest.Layers)); err != nil {
+		log.Printf("[WARN] failed to set layers from registry: %s", err)
+	}
+
+	return nil
+}
+
+// defaultImagePlatform is the platform multi-platform images are resolved to
+// if the platform of the Docker daemon is not known
+const defaultImagePlatform = "linux/amd64"
+
+// dockerDaemonPlatform returns the platform of the Docker daemon, which
+// multi-platform images are resolved to when they are pulled. Without a
+// reachable daemon, the default platform is returned.
+func dockerDaemonPlatform(providerConfig *ProviderConfig) string {
+	if providerConfig.DockerClient == nil {
+		return defaultImagePlatform
+	}
+	version, err := providerConfig.DockerClient.ServerVersion(context.Background())
+	if err != nil {
+		log.Printf("[WARN] Unable to get the platform of the Docker daemon, using %s: %s", defaultImagePlatform, err)
+		return defaultImagePlatform
+	}
+	return formatPlatform(version.Os, version.Arch, "")
+}
+
+// parseRegistryImageOptions splits an image name into the registry, the
+// repository path on the registry and the tag, filling in the defaults of
+// the Docker Hub.
+func parseRegistryImageOptions(name string) internalPullImageOptions {
+	pullOpts := parseImageOptions(name)
 
 	// Use the official Docker Hub if a registry isn't specified
 	if pullOpts.Registry == "" {
@@ -44,6 +190,12 @@ func dataSourceDockerRegistryImageRead(d *schema.ResourceData, meta interface{})
 		pullOpts.Repository = strings.Replace(pullOpts.Repository, pullOpts.Registry+"/", "", 1)
 	}
 
+	// The other names of the Docker Hub refer to the same repositories
+	switch pullOpts.Registry {
+	case "docker.io", "index.docker.io", "registry-1.docker.io":
+		pullOpts.Registry = "registry.hub.docker.com"
+	}
+
 	if pullOpts.Registry == "registry.hub.docker.com" {
 		// Docker prefixes 'library' to official images in the path; 'consul' becomes 'library/consul'
 		if !strings.Contains(pullOpts.Repository, "/") {
@@ -55,148 +207,330 @@ func dataSourceDockerRegistryImageRead(d *schema.ResourceData, meta interface{})
 		pullOpts.Tag = "latest"
 	}
 
-	username := ""
-	password := ""
+	return pullOpts
+}
 
-	if auth, ok := authConfig.Configs[normalizeRegistryAddress(pullOpts.Registry)]; ok {
-		username = auth.Username
-		password = auth.Password
+// getRegistryCredentials returns the credentials configured for the registry,
+// a username and password or an identity token
+func getRegistryCredentials(registry string, authConfigs *AuthConfigs) types.AuthConfig {
+	if auth, ok := authConfigs.Configs[normalizeRegistryAddress(registry)]; ok {
+		return auth
 	}
+	return types.AuthConfig{}
+}
 
-	digest, err := getImageDigest(pullOpts.Registry, pullOpts.Repository, pullOpts.Tag, username, password, false)
-
+// getRegistryImageDigest fetches the digest of the manifest of the given image
+// from its registry, along with the platforms a multi-platform image is
+// available for. If a platform is given, a multi-platform image is resolved to
+// the digest of the manifest for that platform.
+func getRegistryImageDigest(name, platform string, providerConfig *ProviderConfig) (string, []string, error) {
+	pullOpts := parseRegistryImageOptions(name)
+	registry, err := newRegistryConnection(pullOpts.Registry, providerConfig)
 	if err != nil {
-		digest, err = getImageDigest(pullOpts.Registry, pullOpts.Repository, pullOpts.Tag, username, password, true)
-		if err != nil {
-			return fmt.Errorf("Got error when attempting to fetch image version from registry: %s", err)
-		}
+		return "", nil, err
 	}
 
-	d.SetId(digest)
-	d.Set("sha256_digest", digest)
+	digest, platforms, err := getImageDigest(registry, pullOpts.Repository, pullOpts.Tag, platform, false)
+	if err == errRegistryManifestRejected {
+		digest, platforms, err = getImageDigest(registry, pullOpts.Repository, pullOpts.Tag, platform, true)
+	}
+	return digest, platforms, err
+}
 
-	return nil
+// errRegistryImageNotFound is returned when the registry does not know the requested manifest
+var errRegistryImageNotFound = errors.New("Image not found in registry")
+
+// errRegistryManifestRejected is returned when the registry does not serve the
+// manifest in any of the accepted media types
+var errRegistryManifestRejected = errors.New("Registry does not support the accepted manifest media types")
+
+// registryPlatformError is returned when a multi-platform image has no
+// manifest for the requested platform
+type registryPlatformError struct {
+	image     string
+	platform  string
+	platforms []string
 }
 
-func getImageDigest(registry, image, tag, username, password string, fallback bool) (string, error) {
-	client := http.DefaultClient
+func (e *registryPlatformError) Error() string {
+	return fmt.Sprintf("Image %s is not available for platform %s, only for: %s", e.image, e.platform, strings.Join(e.platforms, ", "))
+}
 
-	// Allow insecure registries only for ACC tests
-	// cuz we don't have a valid certs for this case
-	if env, okEnv := os.LookupEnv("TF_ACC"); okEnv {
-		if i, errConv := strconv.Atoi(env); errConv == nil && i >= 1 {
-			cfg := &tls.Config{
-				InsecureSkipVerify: true,
-			}
-			client.Transport = &http.Transport{
-				TLSClientConfig: cfg,
-			}
-		}
+// Media types of the manifests served by registries
+const (
+	manifestV1MediaType   = "application/vnd.docker.distribution.manifest.v1+prettyjws"
+	manifestV2MediaType   = "application/vnd.docker.distribution.manifest.v2+json"
+	manifestListMediaType = "application/vnd.docker.distribution.manifest.list.v2+json"
+	ociManifestMediaType  = "application/vnd.oci.image.manifest.v1+json"
+	ociIndexMediaType     = "application/vnd.oci.image.index.v1+json"
+)
+
+// platformPattern matches platforms in the os/architecture[/variant] format
+const platformPattern = `^[a-z0-9]+/[a-z0-9_]+(/[a-z0-9]+)?$`
+
+// registryManifestList is a Docker manifest list or an OCI image index
+type registryManifestList struct {
+	MediaType string `json:"mediaType"`
+	Manifests []struct {
+		MediaType string `json:"mediaType"`
+		Digest    string `json:"digest"`
+		Platform  struct {
+			Architecture string `json:"architecture"`
+			OS           string `json:"os"`
+			Variant      string `json:"variant,omitempty"`
+		} `json:"platform"`
+	} `json:"manifests"`
+}
+
+func getImageDigest(registry *registryConnection, image, tag, platform string, fallback bool) (string, []string, error) {
+	// Ask for a v2 manifest or a manifest list so that multi-platform images
+	// can be resolved.
+	accept := []string{manifestListMediaType, ociIndexMediaType, manifestV2MediaType, ociManifestMediaType}
+	if fallback {
+		// Fallback to this header if the registry does not support the v2 manifest like gcr.io
+		accept = []string{manifestV1MediaType}
 	}
 
-	req, err := http.NewRequest("GET", "https://"+registry+"/v2/"+image+"/manifests/"+tag, nil)
+	digest, mediaType, body, err := getImageManifest(registry, image, tag, accept)
 	if err != nil {
-		return "", fmt.Errorf("Error creating registry request: %s", err)
+		return "", nil, err
 	}
 
-	if username != "" {
-		req.SetBasicAuth(username, password)
+	if mediaType != manifestListMediaType && mediaType != ociIndexMediaType {
+		return digest, nil, nil
 	}
 
-	// Set this header so that we get the v2 manifest back from the registry.
-	req.Header.Set("Accept", "application/vnd.docker.distribution.manifest.v2+json")
-	if fallback {
-		// Fallback to this header if the registry does not support the v2 manifest like gcr.io
-		req.Header.Set("Accept", "application/vnd.docker.distribution.manifest.v1+prettyjws")
+	manifestList := &registryManifestList{}
+	if err := json.Unmarshal(body, manifestList); err != nil {
+		return "", nil, fmt.Errorf("Error parsing manifest list: %s", err)
+	}
+
+	platforms := make([]string, 0, len(manifestList.Manifests))
+	platformDigest := ""
+	for _, manifest := range manifestList.Manifests {
+		// attestation manifests of BuildKit are listed for the unknown platform
+		if manifest.Platform.OS == "unknown" && manifest.Platform.Architecture == "unknown" {
+			continue
+		}
+		manifestPlatform := formatPlatform(manifest.Platform.OS, manifest.Platform.Architecture, manifest.Platform.Variant)
+		platforms = append(platforms, manifestPlatform)
+		if platform != "" && platformDigest == "" && platformMatches(platform, manifestPlatform) {
+			platformDigest = manifest.Digest
+		}
 	}
 
-	resp, err := client.Do(req)
+	if platform == "" {
+		return digest, platforms, nil
+	}
+	if platformDigest == "" {
+		return "", platforms, &registryPlatformError{image: image + ":" + tag, platform: platform, platforms: platforms}
+	}
+	return platformDigest, platforms, nil
+}
+
+// getImageManifest fetches the manifest with the given reference, a tag or a
+// digest, accepting the given media types. It returns the digest and the media
+// type of the manifest along with its content.
+func getImageManifest(registry *registryConnection, image, reference string, accept []string) (string, string, []byte, error) {
+	req, err := registry.newRequest("GET", "/v2/"+image+"/manifests/"+reference)
+	if err != nil {
+		return "", "", nil, err
+	}
+	req.Header.Set("Accept", strings.Join(accept, ", "))
 
+	resp, err := registry.do(req)
 	if err != nil {
-		return "", fmt.Errorf("Error during registry request: %s", err)
+		return "", "", nil, err
 	}
+	defer resp.Body.Close()
 
 	switch resp.StatusCode {
-	// Basic auth was valid or not needed
 	case http.StatusOK:
-		return resp.Header.Get("Docker-Content-Digest"), nil
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return "", "", nil, fmt.Errorf("Error reading response body: %s", err)
+		}
+		mediaType := strings.TrimSpace(strings.SplitN(resp.Header.Get("Content-Type"), ";", 2)[0])
+		return resp.Header.Get("Docker-Content-Digest"), mediaType, body, nil
 
-	// Either OAuth is required or the basic auth creds were invalid
 	case http.StatusUnauthorized:
-		if strings.HasPrefix(resp.Header.Get("www-authenticate"), "Bearer") {
-			auth := parseAuthHeader(resp.Header.Get("www-authenticate"))
-			params := url.Values{}
-			params.Set("service", auth["service"])
-			params.Set("scope", auth["scope"])
-			tokenRequest, err := http.NewRequest("GET", auth["realm"]+"?"+params.Encode(), nil)
-
-			if err != nil {
-				return "", fmt.Errorf("Error creating registry request: %s", err)
-			}
-
-			if username != "" {
-				tokenRequest.SetBasicAuth(username, password)
-			}
-
-			tokenResponse, err := client.Do(tokenRequest)
-
-			if err != nil {
-				return "", fmt.Errorf("Error during registry request: %s", err)
-			}
-
-			if tokenResponse.StatusCode != http.StatusOK {
-				return "", fmt.Errorf("Got bad response from registry: " + tokenResponse.Status)
-			}
-
-			body, err := ioutil.ReadAll(tokenResponse.Body)
-			if err != nil {
-				return "", fmt.Errorf("Error reading response body: %s", err)
-			}
-
-			token := &TokenResponse{}
-			err = json.Unmarshal(body, token)
-			if err != nil {
-				return "", fmt.Errorf("Error parsing OAuth token response: %s", err)
-			}
-
-			req.Header.Set("Authorization", "Bearer "+token.Token)
-			digestResponse, err := client.Do(req)
-
-			if err != nil {
-				return "", fmt.Errorf("Error during registry request: %s", err)
-			}
-
-			if digestResponse.StatusCode != http.StatusOK {
-				return "", fmt.Errorf("Got bad response from registry: " + digestResponse.Status)
-			}
-
-			return digestResponse.Header.Get("Docker-Content-Digest"), nil
-		}
+		return "", "", nil, fmt.Errorf("Bad credentials: %s", resp.Status)
+
+	case http.StatusNotFound:
+		return "", "", nil, errRegistryImageNotFound
 
-		return "", fmt.Errorf("Bad credentials: " + resp.Status)
+	case http.StatusBadRequest, http.StatusNotAcceptable, http.StatusUnsupportedMediaType:
+		return "", "", nil, errRegistryManifestRejected
 
 		// Some unexpected status was given, return an error
 	default:
-		return "", fmt.Errorf("Got bad response from registry: " + resp.Status)
+		return "", "", nil, fmt.Errorf("Got bad response from registry: %s", resp.Status)
 	}
 }
 
-type TokenResponse struct {
-	Token string
+// registryManifestDescriptor describes the content of a blob
+type registryManifestDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// registryManifest is a Docker v2 manifest or an OCI image manifest
+type registryManifest struct {
+	MediaType string                       `json:"mediaType"`
+	Config    registryManifestDescriptor   `json:"config"`
+	Layers    []registryManifestDescriptor `json:"layers"`
+}
+
+// registryImageConfig is the part of the config blob of an image the data
+// source exposes
+type registryImageConfig struct {
+	Created string            `json:"created"`
+	Config  *container.Config `json:"config"`
+}
+
+// getRegistryImageConfig fetches the manifest of the image for the platform
+// along with its config blob
+func getRegistryImageConfig(name, platform string, providerConfig *ProviderConfig) (*registryManifest, *registryImageConfig, error) {
+	pullOpts := parseRegistryImageOptions(name)
+	registry, err := newRegistryConnection(pullOpts.Registry, providerConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	digest, _, err := getImageDigest(registry, pullOpts.Repository, pullOpts.Tag, platform, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, mediaType, body, err := getImageManifest(registry, pullOpts.Repository, digest, []string{manifestV2MediaType, ociManifestMediaType})
+	if err != nil {
+		return nil, nil, err
+	}
+	if mediaType != manifestV2MediaType && mediaType != ociManifestMediaType {
+		return nil, nil, fmt.Errorf("Manifests of media type %s have no image config", mediaType)
+	}
+
+	manifest := &registryManifest{}
+	if err := json.Unmarshal(body, manifest); err != nil {
+		return nil, nil, fmt.Errorf("Error parsing manifest: %s", err)
+	}
+
+	blob, err := getRegistryBlob(registry, pullOpts.Repository, manifest.Config.Digest)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	imageConfig := &registryImageConfig{}
+	if err := json.Unmarshal(blob, imageConfig); err != nil {
+		return nil, nil, fmt.Errorf("Error parsing image config: %s", err)
+	}
+	return manifest, imageConfig, nil
 }
 
-// Parses key/value pairs from a WWW-Authenticate header
-func parseAuthHeader(header string) map[string]string {
-	parts := strings.SplitN(header, " ", 2)
-	parts = strings.Split(parts[1], ",")
-	opts := make(map[string]string)
+// getRegistryBlob fetches the blob with the given digest and verifies its
+// content against the digest
+func getRegistryBlob(registry *registryConnection, image, digest string) ([]byte, error) {
+	if !strings.HasPrefix(digest, "sha256:") {
+		return nil, fmt.Errorf("Unsupported digest of blob: %s", digest)
+	}
 
-	for _, part := range parts {
-		vals := strings.SplitN(part, "=", 2)
-		key := vals[0]
-		val := strings.Trim(vals[1], "\", ")
-		opts[key] = val
+	blob, err := openRegistryBlob(registry, image, digest)
+	if err != nil {
+		return nil, err
 	}
+	defer blob.Close()
 
-	return opts
+	body, err := ioutil.ReadAll(blob)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading response body: %s", err)
+	}
+	sum := sha256.Sum256(body)
+	if "sha256:"+hex.EncodeToString(sum[:]) != digest {
+		return nil, fmt.Errorf("Content of blob %s does not match its digest", digest)
+	}
+	return body, nil
+}
+
+// openRegistryBlob starts the download of the blob with the given digest. The
+// caller has to close the returned content.
+func openRegistryBlob(registry *registryConnection, image, digest string) (io.ReadCloser, error) {
+	req, err := registry.newRequest("GET", "/v2/"+image+"/blobs/"+digest)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := registry.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		return resp.Body, nil
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return nil, fmt.Errorf("Bad credentials: %s", resp.Status)
+
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("Blob %s not found in registry", digest)
+
+	default:
+		return nil, fmt.Errorf("Got bad response from registry: %s", resp.Status)
+	}
+}
+
+// formatPlatform formats a platform in the os/architecture[/variant] format
+func formatPlatform(os, architecture, variant string) string {
+	platform := os + "/" + architecture
+	if variant != "" {
+		platform += "/" + variant
+	}
+	return platform
+}
+
+// platformMatches reports whether the available platform satisfies the wanted
+// one. A wanted platform without a variant matches every variant.
+func platformMatches(wanted, available string) bool {
+	wantedParts := strings.Split(strings.ToLower(wanted), "/")
+	availableParts := strings.Split(strings.ToLower(available), "/")
+	if len(wantedParts) < 2 || len(availableParts) < 2 {
+		return false
+	}
+	if wantedParts[0] != availableParts[0] || wantedParts[1] != availableParts[1] {
+		return false
+	}
+	if len(wantedParts) == 3 {
+		return len(availableParts) == 3 && wantedParts[2] == availableParts[2]
+	}
+	return true
+}
+
+// deleteRegistryImage deletes the manifest with the given digest from the registry.
+// A manifest which is already gone is not an error.
+func deleteRegistryImage(registry *registryConnection, image, digest string) error {
+	req, err := registry.newRequest("DELETE", "/v2/"+image+"/manifests/"+digest)
+	if err != nil {
+		return err
+	}
+
+	resp, err := registry.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusAccepted, http.StatusNotFound:
+		return nil
+
+	case http.StatusUnauthorized:
+		return fmt.Errorf("Bad credentials: %s", resp.Status)
+
+	default:
+		return fmt.Errorf("Got bad response from registry: %s", resp.Status)
+	}
 }
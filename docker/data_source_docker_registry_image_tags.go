@@ -0,0 +1,199 @@
+package docker
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func dataSourceDockerRegistryImageTags() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceDockerRegistryImageTagsRead,
+
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Type:        schema.TypeString,
+				Description: "Name of the repository, without a tag",
+				Required:    true,
+			},
+
+			"filter": {
+				Type:         schema.TypeString,
+				Description:  "Regular expression the tags have to match",
+				Optional:     true,
+				ValidateFunc: validateStringIsRegexp(),
+			},
+
+			"version_constraint": {
+				Type:         schema.TypeString,
+				Description:  "Version constraint the tags have to satisfy, e.g. ~> 1.4.0",
+				Optional:     true,
+				ValidateFunc: validateStringIsVersionConstraint(),
+			},
+
+			"tags": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+		},
+	}
+}
+
+func dataSourceDockerRegistryImageTagsRead(d *schema.ResourceData, meta interface{}) error {
+	name := d.Get("name").(string)
+	if tagIndex := strings.LastIndex(name, ":"); tagIndex > strings.LastIndex(name, "/") || strings.Contains(name, "@") {
+		return fmt.Errorf("The name of the repository %s must not contain a tag or digest", name)
+	}
+
+	pullOpts := parseRegistryImageOptions(name)
+	registry, err := newRegistryConnection(pullOpts.Registry, meta.(*ProviderConfig))
+	if err != nil {
+		return err
+	}
+
+	tags, err := getRegistryImageTags(registry, pullOpts.Repository)
+	if err != nil {
+		return fmt.Errorf("Got error when attempting to list the tags of %s: %s", name, err)
+	}
+
+	tags, err = filterImageTags(tags, d.Get("filter").(string), d.Get("version_constraint").(string))
+	if err != nil {
+		return err
+	}
+
+	d.SetId(name)
+	d.Set("tags", tags)
+
+	return nil
+}
+
+// getRegistryImageTags lists the tags of the repository, following the
+// pagination links of the registry
+func getRegistryImageTags(registry *registryConnection, image string) ([]string, error) {
+	tags := []string{}
+	next := "/v2/" + image + "/tags/list"
+	for next != "" {
+		req, err := registry.newRequest("GET", next)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := registry.do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Error reading response body: %s", err)
+		}
+
+		switch resp.StatusCode {
+		case http.StatusOK:
+		case http.StatusUnauthorized:
+			return nil, fmt.Errorf("Bad credentials: %s", resp.Status)
+		case http.StatusNotFound:
+			return nil, errRegistryImageNotFound
+		default:
+			return nil, fmt.Errorf("Got bad response from registry: %s", resp.Status)
+		}
+
+		page := struct {
+			Tags []string `json:"tags"`
+		}{}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, fmt.Errorf("Error parsing tag list: %s", err)
+		}
+		tags = append(tags, page.Tags...)
+
+		next, err = nextRegistryPage(resp.Header.Get("Link"))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tags, nil
+}
+
+// nextRegistryPage returns the path and query of the next page announced by
+// the Link header of a paginated response, or "" for the last page
+func nextRegistryPage(link string) (string, error) {
+	if link == "" {
+		return "", nil
+	}
+
+	// e.g. </v2/tftest/image/tags/list?last=v2&n=100>; rel="next"
+	start := strings.Index(link, "<")
+	end := strings.Index(link, ">")
+	if start == -1 || end < start || !strings.Contains(link[end:], `rel="next"`) {
+		return "", nil
+	}
+
+	next, err := url.Parse(link[start+1 : end])
+	if err != nil {
+		return "", fmt.Errorf("Error parsing the link to the next page: %s", err)
+	}
+	return next.RequestURI(), nil
+}
+
+// filterImageTags returns the tags matching the regular expression and the
+// version constraint, if given, sorted by version from the newest to the
+// oldest. Tags which aren't versions come last, in lexical order.
+func filterImageTags(tags []string, filter, constraint string) ([]string, error) {
+	var filterRegexp *regexp.Regexp
+	if filter != "" {
+		var err error
+		if filterRegexp, err = regexp.Compile(filter); err != nil {
+			return nil, fmt.Errorf("Error parsing filter %s: %s", filter, err)
+		}
+	}
+
+	var constraints version.Constraints
+	if constraint != "" {
+		var err error
+		if constraints, err = version.NewConstraint(constraint); err != nil {
+			return nil, fmt.Errorf("Error parsing version constraint %s: %s", constraint, err)
+		}
+	}
+
+	filtered := []string{}
+	versions := map[string]*version.Version{}
+	for _, tag := range tags {
+		if filterRegexp != nil && !filterRegexp.MatchString(tag) {
+			continue
+		}
+		tagVersion, err := version.NewVersion(tag)
+		if err == nil {
+			versions[tag] = tagVersion
+		}
+		if constraints != nil && (tagVersion == nil || !constraints.Check(tagVersion)) {
+			continue
+		}
+		filtered = append(filtered, tag)
+	}
+
+	sort.SliceStable(filtered, func(i, j int) bool {
+		first, second := versions[filtered[i]], versions[filtered[j]]
+		switch {
+		case first != nil && second != nil:
+			if first.Equal(second) {
+				return filtered[i] < filtered[j]
+			}
+			return first.GreaterThan(second)
+		case first != nil || second != nil:
+			return first != nil
+		default:
+			return filtered[i] < filtered[j]
+		}
+	})
+	return filtered, nil
+}
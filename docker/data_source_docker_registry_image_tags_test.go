@@ -0,0 +1,128 @@
+package docker
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+)
+
+func TestAccDockerRegistryImageTags_private(t *testing.T) {
+	registry := "127.0.0.1:15000"
+	image := "127.0.0.1:15000/tftest-service"
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(testAccDockerRegistryImageTagsConfig, registry, image, image),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.docker_registry_image_tags.all", "tags.#", "4"),
+					resource.TestCheckResourceAttr("data.docker_registry_image_tags.all", "tags.0", "v3"),
+					resource.TestCheckResourceAttr("data.docker_registry_image_tags.all", "tags.3", "latest"),
+					resource.TestCheckResourceAttr("data.docker_registry_image_tags.constrained", "tags.#", "2"),
+					resource.TestCheckResourceAttr("data.docker_registry_image_tags.constrained", "tags.0", "v3"),
+					resource.TestCheckResourceAttr("data.docker_registry_image_tags.constrained", "tags.1", "v2"),
+				),
+			},
+		},
+	})
+}
+
+func TestGetRegistryImageTags(t *testing.T) {
+	pages := map[string]string{
+		"":   `{"name":"tftest/image","tags":["v1","v2"]}`,
+		"v2": `{"name":"tftest/image","tags":["v3","latest"]}`,
+	}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/tftest/image/tags/list" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		last := r.URL.Query().Get("last")
+		if last == "" {
+			w.Header().Set("Link", `</v2/tftest/image/tags/list?last=v2&n=2>; rel="next"`)
+		}
+		fmt.Fprint(w, pages[last])
+	}))
+	defer server.Close()
+
+	registry := &registryConnection{client: server.Client(), baseURL: server.URL}
+	tags, err := getRegistryImageTags(registry, "tftest/image")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if expected := []string{"v1", "v2", "v3", "latest"}; !reflect.DeepEqual(tags, expected) {
+		t.Fatalf("expected tags %v, got %v", expected, tags)
+	}
+
+	if _, err := getRegistryImageTags(registry, "tftest/missing"); err != errRegistryImageNotFound {
+		t.Fatalf("expected the image not to be found, got %v", err)
+	}
+}
+
+func TestFilterImageTags(t *testing.T) {
+	tags := []string{"latest", "1.3.9", "1.4.0", "1.4.10", "1.4.2", "1.4.2-alpine", "1.5.0", "v1.4.3", "edge"}
+
+	cases := []struct {
+		filter, constraint string
+		expected           []string
+	}{
+		{"", "", []string{"1.5.0", "1.4.10", "v1.4.3", "1.4.2", "1.4.2-alpine", "1.4.0", "1.3.9", "edge", "latest"}},
+		{"", "~> 1.4.0", []string{"1.4.10", "v1.4.3", "1.4.2", "1.4.0"}},
+		{`-alpine$`, "", []string{"1.4.2-alpine"}},
+		{`^1\.`, ">= 1.4, < 1.5", []string{"1.4.10", "1.4.2", "1.4.0"}},
+		{`^nothing$`, "", []string{}},
+	}
+
+	for _, c := range cases {
+		filtered, err := filterImageTags(tags, c.filter, c.constraint)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(filtered, c.expected) {
+			t.Errorf("expected filter %q and constraint %q to return %v, got %v", c.filter, c.constraint, c.expected, filtered)
+		}
+	}
+}
+
+func TestNextRegistryPage(t *testing.T) {
+	cases := map[string]string{
+		"": "",
+		`</v2/tftest/image/tags/list?last=v2&n=2>; rel="next"`:                         "/v2/tftest/image/tags/list?last=v2&n=2",
+		`<https://registry.example.com/v2/tftest/image/tags/list?last=v2>; rel="next"`: "/v2/tftest/image/tags/list?last=v2",
+		`</v2/tftest/image/tags/list?last=v2>; rel="prev"`:                             "",
+	}
+
+	for link, expected := range cases {
+		next, err := nextRegistryPage(link)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if next != expected {
+			t.Errorf("expected the next page of %q to be %q, got %q", link, expected, next)
+		}
+	}
+}
+
+const testAccDockerRegistryImageTagsConfig = `
+provider "docker" {
+	alias = "private"
+	registry_auth {
+		address = "%s"
+		insecure_skip_verify = true
+	}
+}
+data "docker_registry_image_tags" "all" {
+	provider = "docker.private"
+	name = "%s"
+}
+data "docker_registry_image_tags" "constrained" {
+	provider = "docker.private"
+	name = "%s"
+	version_constraint = ">= 2"
+}
+`
@@ -1,11 +1,19 @@
 package docker
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
 	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/terraform"
 )
 
 var registryDigestRegexp = regexp.MustCompile(`\A[A-Za-z0-9_\+\.-]+:[A-Fa-f0-9]+\z`)
@@ -58,6 +66,263 @@ func TestAccDockerRegistryImage_auth(t *testing.T) {
 	})
 }
 
+func TestAccDockerRegistryImage_platform(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDockerImageDataSourcePlatformConfig,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestMatchResourceAttr("data.docker_registry_image.amd64", "sha256_digest", registryDigestRegexp),
+					resource.TestMatchResourceAttr("data.docker_registry_image.arm64", "sha256_digest", registryDigestRegexp),
+					resource.TestMatchResourceAttr("data.docker_registry_image.list", "platforms.#", regexp.MustCompile(`\A[1-9][0-9]*\z`)),
+					testAccCheckAttrsDiffer("data.docker_registry_image.amd64", "data.docker_registry_image.arm64", "sha256_digest"),
+					testAccCheckAttrsDiffer("data.docker_registry_image.list", "data.docker_registry_image.amd64", "sha256_digest"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccDockerRegistryImage_fetchConfig(t *testing.T) {
+	registry := "127.0.0.1:15000"
+	image := "127.0.0.1:15000/tftest-service:v1"
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(testAccDockerImageDataSourceFetchConfig, registry, image),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.docker_registry_image.foobar", "cmd.0", "node"),
+					resource.TestCheckResourceAttr("data.docker_registry_image.foobar", "cmd.1", "server.js"),
+					resource.TestCheckResourceAttr("data.docker_registry_image.foobar", "exposed_ports.0.port", "8080"),
+					resource.TestCheckResourceAttr("data.docker_registry_image.foobar", "exposed_ports.0.protocol", "tcp"),
+					resource.TestMatchResourceAttr("data.docker_registry_image.foobar", "layers.#", regexp.MustCompile(`\A[1-9][0-9]*\z`)),
+					resource.TestMatchResourceAttr("data.docker_registry_image.foobar", "layers.0.digest", registryDigestRegexp),
+					resource.TestMatchResourceAttr("data.docker_registry_image.foobar", "created", regexp.MustCompile(`\A[0-9]{4}-`)),
+				),
+			},
+		},
+	})
+}
+
+func TestGetRegistryImageConfig(t *testing.T) {
+	config := `{"created":"2019-10-01T12:00:00Z","architecture":"arm64","os":"linux","config":{"Env":["PATH=/bin"],"Cmd":["node","server.js"],"Labels":{"org.opencontainers.image.revision":"abc123"},"ExposedPorts":{"8080/tcp":{}}}}`
+	configSum := sha256.Sum256([]byte(config))
+	configDigest := "sha256:" + hex.EncodeToString(configSum[:])
+	manifest := fmt.Sprintf(`{"schemaVersion":2,"mediaType":"%s","config":{"mediaType":"application/vnd.docker.container.image.v1+json","digest":"%s","size":%d},"layers":[{"mediaType":"application/vnd.docker.image.rootfs.diff.tar.gzip","digest":"sha256:aaaa","size":1024},{"mediaType":"application/vnd.docker.image.rootfs.diff.tar.gzip","digest":"sha256:bbbb","size":2048}]}`, manifestV2MediaType, configDigest, len(config))
+	manifestList := fmt.Sprintf(`{"schemaVersion":2,"mediaType":"%s","manifests":[{"digest":"sha256:amd64","platform":{"architecture":"amd64","os":"linux"}},{"digest":"sha256:arm64","platform":{"architecture":"arm64","os":"linux","variant":"v8"}},{"digest":"sha256:attestation","platform":{"architecture":"unknown","os":"unknown"}}]}`, manifestListMediaType)
+	blobs := map[string]string{configDigest: config, "sha256:cccc": config}
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/tftest/image/manifests/latest":
+			w.Header().Set("Content-Type", manifestListMediaType)
+			w.Header().Set("Docker-Content-Digest", "sha256:list")
+			fmt.Fprint(w, manifestList)
+		case "/v2/tftest/image/manifests/sha256:arm64":
+			w.Header().Set("Content-Type", manifestV2MediaType)
+			w.Header().Set("Docker-Content-Digest", "sha256:arm64")
+			fmt.Fprint(w, manifest)
+		default:
+			blob, ok := blobs[strings.TrimPrefix(r.URL.Path, "/v2/tftest/image/blobs/")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			fmt.Fprint(w, blob)
+		}
+	}))
+	defer server.Close()
+	registry := strings.TrimPrefix(server.URL, "https://")
+	providerConfig := &ProviderConfig{AuthConfigs: &AuthConfigs{
+		Registries: map[string]registryOptions{normalizeRegistryAddress(registry): {InsecureSkipVerify: true}},
+	}}
+
+	registryManifest, imageConfig, err := getRegistryImageConfig(registry+"/tftest/image", "linux/arm64", providerConfig)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if imageConfig.Created != "2019-10-01T12:00:00Z" || imageConfig.Config.Labels["org.opencontainers.image.revision"] != "abc123" {
+		t.Fatalf("unexpected image config: %+v", imageConfig)
+	}
+	if !reflect.DeepEqual([]string(imageConfig.Config.Cmd), []string{"node", "server.js"}) {
+		t.Fatalf("unexpected cmd: %v", imageConfig.Config.Cmd)
+	}
+	expectedLayers := []interface{}{
+		map[string]interface{}{"digest": "sha256:aaaa", "size": 1024, "media_type": "application/vnd.docker.image.rootfs.diff.tar.gzip"},
+		map[string]interface{}{"digest": "sha256:bbbb", "size": 2048, "media_type": "application/vnd.docker.image.rootfs.diff.tar.gzip"},
+	}
+	if layers := flattenRegistryImageLayers(registryManifest.Layers); !reflect.DeepEqual(layers, expectedLayers) {
+		t.Fatalf("expected layers %v, got %v", expectedLayers, layers)
+	}
+
+	if _, _, err := getRegistryImageConfig(registry+"/tftest/image", "linux/s390x", providerConfig); err == nil {
+		t.Fatal("expected an error for a platform the image is not available for")
+	}
+
+	registryConnection, err := newRegistryConnection(registry, providerConfig)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := getRegistryBlob(registryConnection, "tftest/image", "sha256:cccc"); err == nil || !strings.Contains(err.Error(), "does not match its digest") {
+		t.Fatalf("expected an error for a blob not matching its digest, got %v", err)
+	}
+
+	// attestations are not listed as platforms
+	digest, platforms, err := getImageDigest(registryConnection, "tftest/image", "latest", "", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if expected := []string{"linux/amd64", "linux/arm64/v8"}; digest != "sha256:list" || !reflect.DeepEqual(platforms, expected) {
+		t.Fatalf("expected the digest of the list and platforms %v, got %s and %v", expected, digest, platforms)
+	}
+}
+
+func TestGetRegistryImageDigestFallback(t *testing.T) {
+	manifestList := fmt.Sprintf(`{"schemaVersion":2,"mediaType":"%s","manifests":[{"digest":"sha256:amd64","platform":{"architecture":"amd64","os":"linux"}}]}`, manifestListMediaType)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept := r.Header.Get("Accept")
+		switch {
+		case accept == manifestV1MediaType:
+			w.Header().Set("Content-Type", manifestV1MediaType)
+			w.Header().Set("Docker-Content-Digest", "sha256:v1converted")
+			fmt.Fprint(w, `{"schemaVersion":1}`)
+		case r.URL.Path == "/v2/tftest/image/manifests/latest":
+			w.Header().Set("Content-Type", manifestListMediaType)
+			w.Header().Set("Docker-Content-Digest", "sha256:list")
+			fmt.Fprint(w, manifestList)
+		default:
+			// the v1 image is only served with the v1 media type
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+		}
+	}))
+	defer server.Close()
+	registry := strings.TrimPrefix(server.URL, "https://")
+	providerConfig := &ProviderConfig{AuthConfigs: &AuthConfigs{
+		Registries: map[string]registryOptions{normalizeRegistryAddress(registry): {InsecureSkipVerify: true}},
+	}}
+
+	digest, platforms, err := getRegistryImageDigest(registry+"/tftest/image:latest", "linux/s390x", providerConfig)
+	if _, ok := err.(*registryPlatformError); !ok {
+		t.Fatalf("expected an error for a platform the image is not available for, got %s, %v and %v", digest, platforms, err)
+	}
+
+	digest, _, err = getRegistryImageDigest(registry+"/tftest/image:latest", "linux/amd64", providerConfig)
+	if err != nil || digest != "sha256:amd64" {
+		t.Fatalf("expected the digest of the platform, got %s and %v", digest, err)
+	}
+
+	digest, _, err = getRegistryImageDigest(registry+"/tftest/image:v1", "", providerConfig)
+	if err != nil || digest != "sha256:v1converted" {
+		t.Fatalf("expected the digest of the v1 manifest, got %s and %v", digest, err)
+	}
+}
+
+func TestDockerRegistryImageReadsConfigByDigest(t *testing.T) {
+	registry := newTestRegistry("")
+	defer registry.server.Close()
+	address := strings.TrimPrefix(registry.server.URL, "https://")
+
+	digests := map[string]string{}
+	for _, release := range []string{"1.0", "2.0"} {
+		config := registry.addBlob("tftest/image", []byte(`{"config":{"Labels":{"release":"`+release+`"}}}`))
+		digests[release] = registry.addManifest("tftest/image", release, manifestV2MediaType, registryManifest{
+			MediaType: manifestV2MediaType,
+			Config:    config,
+		})
+	}
+	registry.manifests["tftest/image:latest"] = registry.manifests["tftest/image:1.0"]
+
+	// the tag is moved right after it was resolved
+	registry.server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		registry.serveHTTP(w, r)
+		if r.URL.Path == "/v2/tftest/image/manifests/latest" {
+			registry.manifests["tftest/image:latest"] = registry.manifests["tftest/image:2.0"]
+		}
+	})
+
+	providerConfig := &ProviderConfig{AuthConfigs: &AuthConfigs{
+		Registries: map[string]registryOptions{normalizeRegistryAddress(address): {InsecureSkipVerify: true}},
+	}}
+	d := schema.TestResourceDataRaw(t, dataSourceDockerRegistryImage().Schema, map[string]interface{}{
+		"name":         address + "/tftest/image:latest",
+		"fetch_config": true,
+	})
+	if err := dataSourceDockerRegistryImageRead(d, providerConfig); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if d.Get("sha256_digest").(string) != digests["1.0"] || d.Get("labels.release").(string) != "1.0" {
+		t.Fatalf("expected the digest and config of release 1.0, got %s and release %s", d.Get("sha256_digest"), d.Get("labels.release"))
+	}
+}
+
+func TestParseRegistryImageOptions(t *testing.T) {
+	cases := map[string][3]string{
+		"alpine":                               {"registry.hub.docker.com", "library/alpine", "latest"},
+		"docker.io/library/alpine:3.10":        {"registry.hub.docker.com", "library/alpine", "3.10"},
+		"docker.io/alpine":                     {"registry.hub.docker.com", "library/alpine", "latest"},
+		"index.docker.io/team/app@sha256:1234": {"registry.hub.docker.com", "team/app", "sha256:1234"},
+		"127.0.0.1:15000/tftest-service:v1":    {"127.0.0.1:15000", "tftest-service", "v1"},
+	}
+
+	for name, expected := range cases {
+		pullOpts := parseRegistryImageOptions(name)
+		if got := [3]string{pullOpts.Registry, pullOpts.Repository, pullOpts.Tag}; got != expected {
+			t.Errorf("expected %s to be parsed as %v, got %v", name, expected, got)
+		}
+	}
+}
+
+func TestPlatformMatches(t *testing.T) {
+	cases := []struct {
+		wanted, available string
+		matches           bool
+	}{
+		{"linux/amd64", "linux/amd64", true},
+		{"linux/arm64", "linux/amd64", false},
+		{"linux/arm64", "linux/arm64/v8", true},
+		{"linux/arm/v7", "linux/arm/v7", true},
+		{"linux/arm/v7", "linux/arm/v6", false},
+		{"linux/arm/v7", "linux/arm", false},
+		{"windows/amd64", "linux/amd64", false},
+	}
+
+	for _, c := range cases {
+		if platformMatches(c.wanted, c.available) != c.matches {
+			t.Errorf("expected platformMatches(%q, %q) to be %t", c.wanted, c.available, c.matches)
+		}
+	}
+}
+
+func testAccCheckAttrsDiffer(first, second, key string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		firstValue := s.RootModule().Resources[first].Primary.Attributes[key]
+		secondValue := s.RootModule().Resources[second].Primary.Attributes[key]
+		if firstValue == secondValue {
+			return fmt.Errorf("Expected %s of %s and %s to differ, both are %s", key, first, second, firstValue)
+		}
+		return nil
+	}
+}
+
+func TestAccDockerRegistryImage_unsigned(t *testing.T) {
+	registry := "127.0.0.1:15000"
+	image := "127.0.0.1:15000/tftest-service:v1"
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:      fmt.Sprintf(testAccDockerRegistryImageSignatureConfig, registry, image, testSignaturePublicKey),
+				ExpectError: regexp.MustCompile(`No signature found for tftest-service@sha256:`),
+			},
+		},
+	})
+}
+
 const testAccDockerImageDataSourceConfig = `
 data "docker_registry_image" "foo" {
 	name = "alpine:latest"
@@ -70,15 +335,62 @@ data "docker_registry_image" "bar" {
 }
 `
 
+const testAccDockerImageDataSourceFetchConfig = `
+provider "docker" {
+	alias = "private"
+	registry_auth {
+		address = "%s"
+		insecure_skip_verify = true
+	}
+}
+data "docker_registry_image" "foobar" {
+	provider = "docker.private"
+	name = "%s"
+	fetch_config = true
+}
+`
+
 const testAccDockerImageDataSourceAuthConfig = `
 provider "docker" {
 	alias = "private"
 	registry_auth {
 		address = "%s"
+		insecure_skip_verify = true
+	}
+}
+data "docker_registry_image" "foobar" {
+	provider = "docker.private"
+	name = "%s"
+}
+`
+
+const testAccDockerImageDataSourcePlatformConfig = `
+data "docker_registry_image" "list" {
+	name = "alpine:3.10"
+}
+data "docker_registry_image" "amd64" {
+	name = "alpine:3.10"
+	platform = "linux/amd64"
+}
+data "docker_registry_image" "arm64" {
+	name = "alpine:3.10"
+	platform = "linux/arm64"
+}
+`
+
+const testAccDockerRegistryImageSignatureConfig = `
+provider "docker" {
+	alias = "private"
+	registry_auth {
+		address = "%s"
+		insecure_skip_verify = true
 	}
 }
 data "docker_registry_image" "foobar" {
 	provider = "docker.private"
 	name = "%s"
+	signature_public_key = <<EOF
+%s
+EOF
 }
 `
@@ -0,0 +1,91 @@
+package docker
+
+import (
+	"fmt"
+	"log"
+	"sync"
+)
+
+// imagePuller coalesces concurrent pulls of the same image and caps the
+// number of concurrent pulls per registry. Terraform walks the graph in
+// parallel, so several resources referencing the same image would otherwise
+// pull it at the same time.
+type imagePuller struct {
+	maxPullsPerRegistry int
+
+	mu         sync.Mutex
+	inFlight   map[string]*imagePull
+	registries map[string]chan struct{}
+
+	// joined is called when a pull joins the one in progress, before it
+	// waits for it to complete. Tests use it to synchronise with the pulls.
+	joined func(key string)
+}
+
+// imagePull is a pull in progress, err is set before done is closed
+type imagePull struct {
+	done chan struct{}
+	err  error
+}
+
+func newImagePuller(maxPullsPerRegistry int) *imagePuller {
+	return &imagePuller{
+		maxPullsPerRegistry: maxPullsPerRegistry,
+		inFlight:            make(map[string]*imagePull),
+		registries:          make(map[string]chan struct{}),
+	}
+}
+
+// pull runs pullFunc unless a pull with the same key is already in progress,
+// in which case it waits for that pull and returns its error.
+func (p *imagePuller) pull(key, registry string, pullFunc func() error) error {
+	p.mu.Lock()
+	if inFlight, ok := p.inFlight[key]; ok {
+		p.mu.Unlock()
+		log.Printf("[DEBUG] Waiting for the pull of %s already in progress", key)
+		if p.joined != nil {
+			p.joined(key)
+		}
+		<-inFlight.done
+		return inFlight.err
+	}
+
+	// the error is kept for the waiting pulls if pullFunc panics
+	current := &imagePull{
+		done: make(chan struct{}),
+		err:  fmt.Errorf("The pull of %s did not complete", key),
+	}
+	p.inFlight[key] = current
+	slots := p.registrySlots(registry)
+	p.mu.Unlock()
+
+	if slots != nil {
+		slots <- struct{}{}
+	}
+	defer func() {
+		if slots != nil {
+			<-slots
+		}
+		p.mu.Lock()
+		delete(p.inFlight, key)
+		p.mu.Unlock()
+		close(current.done)
+	}()
+
+	current.err = pullFunc()
+	return current.err
+}
+
+// registrySlots returns the semaphore of the registry, nil if pulls are not
+// capped. It must be called with p.mu held.
+func (p *imagePuller) registrySlots(registry string) chan struct{} {
+	if p.maxPullsPerRegistry <= 0 {
+		return nil
+	}
+	slots, ok := p.registries[registry]
+	if !ok {
+		slots = make(chan struct{}, p.maxPullsPerRegistry)
+		p.registries[registry] = slots
+	}
+	return slots
+}
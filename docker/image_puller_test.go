@@ -0,0 +1,106 @@
+package docker
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestImagePullerCoalescesPulls(t *testing.T) {
+	puller := newImagePuller(0)
+	joined := make(chan struct{}, 5)
+	puller.joined = func(string) { joined <- struct{}{} }
+	var pulls int32
+
+	var wg sync.WaitGroup
+	errs := make([]error, 5)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = puller.pull("alpine:3.10|", "registry.hub.docker.com", func() error {
+				atomic.AddInt32(&pulls, 1)
+				// hold the pull until every other caller waits for it
+				for i := 1; i < len(errs); i++ {
+					<-joined
+				}
+				return errors.New("pull failed")
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	if pulls != 1 {
+		t.Fatalf("expected 1 pull, got %d", pulls)
+	}
+	for i, err := range errs {
+		if err == nil || err.Error() != "pull failed" {
+			t.Fatalf("expected the error of the pull for caller %d, got %v", i, err)
+		}
+	}
+
+	// a later pull of the same image is not coalesced with the finished one
+	if err := puller.pull("alpine:3.10|", "registry.hub.docker.com", func() error { return nil }); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestImagePullerReleasesWaitersOnPanic(t *testing.T) {
+	puller := newImagePuller(1)
+	joined := make(chan struct{}, 1)
+	puller.joined = func(string) { joined <- struct{}{} }
+
+	waiter := make(chan error)
+	go func() {
+		defer func() { recover() }()
+		puller.pull("alpine:3.10|", "registry.hub.docker.com", func() error {
+			go func() {
+				waiter <- puller.pull("alpine:3.10|", "registry.hub.docker.com", func() error { return nil })
+			}()
+			<-joined
+			panic("pull panicked")
+		})
+	}()
+
+	if err := <-waiter; err == nil || !strings.Contains(err.Error(), "did not complete") {
+		t.Fatalf("expected the waiting pull to fail, got %v", err)
+	}
+
+	// the registry slot of the panicked pull was released
+	if err := puller.pull("alpine:3.11|", "registry.hub.docker.com", func() error { return nil }); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestImagePullerLimitsPullsPerRegistry(t *testing.T) {
+	puller := newImagePuller(2)
+	var running, maxRunning int32
+
+	var wg sync.WaitGroup
+	for _, image := range []string{"a", "b", "c", "d", "e"} {
+		wg.Add(1)
+		go func(image string) {
+			defer wg.Done()
+			puller.pull(image, "127.0.0.1:15000", func() error {
+				n := atomic.AddInt32(&running, 1)
+				for {
+					max := atomic.LoadInt32(&maxRunning)
+					if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+						break
+					}
+				}
+				time.Sleep(50 * time.Millisecond)
+				atomic.AddInt32(&running, -1)
+				return nil
+			})
+		}(image)
+	}
+	wg.Wait()
+
+	if maxRunning > 2 {
+		t.Fatalf("expected at most 2 concurrent pulls, got %d", maxRunning)
+	}
+}
@@ -11,7 +11,9 @@ import (
 	"log"
 	"os"
 	"os/user"
+	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 
 	credhelper "github.com/docker/docker-credential-helpers/client"
@@ -92,24 +94,58 @@ func Provider() terraform.ResourceProvider {
 							DefaultFunc:   schema.EnvDefaultFunc("DOCKER_CONFIG", "~/.docker/config.json"),
 							Description:   "Path to docker json file for registry auth",
 						},
+
+						"ca_material": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "PEM-encoded CA certificates to verify the registry with",
+						},
+
+						"insecure_skip_verify": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Description: "Skip the verification of the TLS certificate of the registry",
+						},
+
+						"scheme": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      "https",
+							Description:  "Scheme of the registry API, http for registries without TLS",
+							ValidateFunc: validateStringMatchesPattern(`^(https|http)$`),
+						},
 					},
 				},
 			},
+
+			"max_concurrent_pulls_per_registry": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Default:     3,
+				Description: "Maximum number of concurrent image pulls from a single registry, 0 for no limit",
+			},
 		},
 
 		ResourcesMap: map[string]*schema.Resource{
-			"docker_container": resourceDockerContainer(),
-			"docker_image":     resourceDockerImage(),
-			"docker_network":   resourceDockerNetwork(),
-			"docker_volume":    resourceDockerVolume(),
-			"docker_config":    resourceDockerConfig(),
-			"docker_secret":    resourceDockerSecret(),
-			"docker_service":   resourceDockerService(),
+			"docker_container":           resourceDockerContainer(),
+			"docker_image":               resourceDockerImage(),
+			"docker_image_archive":       resourceDockerImageArchive(),
+			"docker_registry_image":      resourceDockerRegistryImage(),
+			"docker_registry_image_copy": resourceDockerRegistryImageCopy(),
+			"docker_tag":                 resourceDockerTag(),
+			"docker_network":             resourceDockerNetwork(),
+			"docker_volume":              resourceDockerVolume(),
+			"docker_config":              resourceDockerConfig(),
+			"docker_secret":              resourceDockerSecret(),
+			"docker_service":             resourceDockerService(),
 		},
 
 		DataSourcesMap: map[string]*schema.Resource{
-			"docker_registry_image": dataSourceDockerRegistryImage(),
-			"docker_network":        dataSourceDockerNetwork(),
+			"docker_registry_image":      dataSourceDockerRegistryImage(),
+			"docker_registry_image_tags": dataSourceDockerRegistryImageTags(),
+			"docker_network":             dataSourceDockerNetwork(),
+			"docker_image":               dataSourceDockerImage(),
+			"docker_container":           dataSourceDockerContainer(),
 		},
 
 		ConfigureFunc: providerConfigure,
@@ -136,24 +172,40 @@ func providerConfigure(d *schema.ResourceData) (interface{}, error) {
 		return nil, fmt.Errorf("Error pinging Docker server: %s", err)
 	}
 
-	authConfigs := &AuthConfigs{}
-
-	if v, ok := d.GetOk("registry_auth"); ok { // TODO load them anyway
-		authConfigs, err = providerSetToRegistryAuth(v.(*schema.Set))
+	// Credentials of the docker CLI are used for the registries without a
+	// registry_auth block
+	authConfigs, err := loadDefaultAuthConfigs()
+	if err != nil {
+		log.Printf("[WARN] Unable to load registry credentials from the Docker config file: %s", err)
+		authConfigs = &AuthConfigs{Configs: make(map[string]types.AuthConfig)}
+	}
 
+	if v, ok := d.GetOk("registry_auth"); ok {
+		registryAuthConfigs, err := providerSetToRegistryAuth(v.(*schema.Set))
 		if err != nil {
 			return nil, fmt.Errorf("Error loading registry auth config: %s", err)
 		}
+
+		for address, authConfig := range registryAuthConfigs.Configs {
+			authConfigs.Configs[address] = authConfig
+		}
+		authConfigs.Registries = registryAuthConfigs.Registries
 	}
 
 	providerConfig := ProviderConfig{
-		DockerClient: client,
-		AuthConfigs:  authConfigs,
+		DockerClient:   client,
+		AuthConfigs:    authConfigs,
+		ImagePuller:    newImagePuller(d.Get("max_concurrent_pulls_per_registry").(int)),
+		RegistryTokens: newRegistryTokenCache(),
 	}
 
 	return &providerConfig, nil
 }
 
+// identityTokenUsername is the username credential helpers store identity
+// tokens with
+const identityTokenUsername = "<token>"
+
 // ErrCannotParseDockercfg is the error returned by NewAuthConfigurations when the dockercfg cannot be parsed.
 var ErrCannotParseDockercfg = errors.New("Failed to read authentication from dockercfg")
 
@@ -161,70 +213,117 @@ var ErrCannotParseDockercfg = errors.New("Failed to read authentication from doc
 // PushImage method accommodating the new X-Registry-Config header
 type AuthConfigs struct {
 	Configs map[string]types.AuthConfig `json:"configs"`
+	// Registries holds the connection options of the registries, keyed like
+	// Configs by normalized registry address
+	Registries map[string]registryOptions `json:"-"`
 }
 
 // dockerConfig represents a registry authentation configuration from the
 // .dockercfg file.
 type dockerConfig struct {
-	Auth  string `json:"auth"`
-	Email string `json:"email"`
+	Auth          string `json:"auth"`
+	Email         string `json:"email"`
+	Username      string `json:"username,omitempty"`
+	Password      string `json:"password,omitempty"`
+	IdentityToken string `json:"identitytoken,omitempty"`
+}
+
+// dockerConfigFile represents the registry authentication configurations of
+// the ~/.docker/config.json file.
+type dockerConfigFile struct {
+	Auths       map[string]dockerConfig `json:"auths"`
+	CredsStore  string                  `json:"credsStore,omitempty"`
+	CredHelpers map[string]string       `json:"credHelpers,omitempty"`
 }
 
 // Take the given registry_auth schemas and return a map of registry auth configurations
 func providerSetToRegistryAuth(authSet *schema.Set) (*AuthConfigs, error) {
 	authConfigs := AuthConfigs{
-		Configs: make(map[string]types.AuthConfig),
+		Configs:    make(map[string]types.AuthConfig),
+		Registries: make(map[string]registryOptions),
 	}
 
 	for _, authInt := range authSet.List() {
 		auth := authInt.(map[string]interface{})
-		authConfig := types.AuthConfig{}
-		authConfig.ServerAddress = normalizeRegistryAddress(auth["address"].(string))
-
-		// For each registry_auth block, generate an AuthConfiguration using either
-		// username/password or the given config file
-		if username, ok := auth["username"]; ok && username.(string) != "" {
-			authConfig.Username = auth["username"].(string)
-			authConfig.Password = auth["password"].(string)
-		} else if configFile, ok := auth["config_file"]; ok && configFile.(string) != "" {
-			filePath := configFile.(string)
-			if strings.HasPrefix(filePath, "~/") {
-				usr, err := user.Current()
-				if err != nil {
-					return nil, err
-				}
-				filePath = strings.Replace(filePath, "~", usr.HomeDir, 1)
-			}
+		authConfig, err := registryAuthToAuthConfig(auth)
+		if err != nil {
+			return nil, err
+		}
+		authConfigs.Configs[authConfig.ServerAddress] = authConfig
+		authConfigs.Registries[authConfig.ServerAddress] = registryAuthToRegistryOptions(auth)
+	}
 
-			r, err := os.Open(filePath)
-			if err != nil {
-				return nil, fmt.Errorf("Error opening docker registry config file: %v", err)
-			}
+	return &authConfigs, nil
+}
 
-			auths, err := newAuthConfigurations(r)
+// registryAuthToRegistryOptions maps a registry_auth block to the options of
+// the connection to its registry
+func registryAuthToRegistryOptions(auth map[string]interface{}) registryOptions {
+	options := registryOptions{}
+	if scheme, ok := auth["scheme"]; ok {
+		options.Scheme = scheme.(string)
+	}
+	if strings.HasPrefix(auth["address"].(string), "http://") {
+		options.Scheme = "http"
+	}
+	if caMaterial, ok := auth["ca_material"]; ok {
+		options.CAMaterial = caMaterial.(string)
+	}
+	if insecureSkipVerify, ok := auth["insecure_skip_verify"]; ok {
+		options.InsecureSkipVerify = insecureSkipVerify.(bool)
+	}
+	return options
+}
+
+// registryAuthToAuthConfig maps a registry_auth block to the credentials of
+// its registry, given either as username/password or by a config file
+func registryAuthToAuthConfig(auth map[string]interface{}) (types.AuthConfig, error) {
+	authConfig := types.AuthConfig{}
+	// the scheme of plain HTTP registries is kept in their registryOptions, so
+	// that they are found by the same address as the other registries
+	authConfig.ServerAddress = normalizeRegistryAddress(strings.TrimPrefix(auth["address"].(string), "http://"))
+
+	if username, ok := auth["username"]; ok && username.(string) != "" {
+		authConfig.Username = auth["username"].(string)
+		authConfig.Password = auth["password"].(string)
+	} else if configFile, ok := auth["config_file"]; ok && configFile.(string) != "" {
+		filePath := configFile.(string)
+		if strings.HasPrefix(filePath, "~/") {
+			usr, err := user.Current()
 			if err != nil {
-				return nil, fmt.Errorf("Error parsing docker registry config json: %v", err)
+				return authConfig, err
 			}
+			filePath = strings.Replace(filePath, "~", usr.HomeDir, 1)
+		}
 
-			foundRegistry := false
-			for registry, authFileConfig := range auths.Configs {
-				if authConfig.ServerAddress == normalizeRegistryAddress(registry) {
-					authConfig.Username = authFileConfig.Username
-					authConfig.Password = authFileConfig.Password
-					foundRegistry = true
-				}
-			}
+		r, err := os.Open(filePath)
+		if err != nil {
+			return authConfig, fmt.Errorf("Error opening docker registry config file: %v", err)
+		}
+		defer r.Close()
+
+		auths, err := newAuthConfigurations(r)
+		if err != nil {
+			return authConfig, fmt.Errorf("Error parsing docker registry config json: %v", err)
+		}
 
-			if !foundRegistry {
-				return nil, fmt.Errorf("Couldn't find registry config for '%s' in file: %s",
-					authConfig.ServerAddress, filePath)
+		foundRegistry := false
+		for registry, authFileConfig := range auths.Configs {
+			if authConfig.ServerAddress == normalizeRegistryAddress(registry) {
+				authConfig.Username = authFileConfig.Username
+				authConfig.Password = authFileConfig.Password
+				authConfig.IdentityToken = authFileConfig.IdentityToken
+				foundRegistry = true
 			}
 		}
 
-		authConfigs.Configs[authConfig.ServerAddress] = authConfig
+		if !foundRegistry {
+			return authConfig, fmt.Errorf("Couldn't find registry config for '%s' in file: %s",
+				authConfig.ServerAddress, filePath)
+		}
 	}
 
-	return &authConfigs, nil
+	return authConfig, nil
 }
 
 // newAuthConfigurations returns AuthConfigs from a JSON encoded string in the
@@ -232,75 +331,184 @@ func providerSetToRegistryAuth(authSet *schema.Set) (*AuthConfigs, error) {
 func newAuthConfigurations(r io.Reader) (*AuthConfigs, error) {
 	var auth *AuthConfigs
 	log.Println("[DEBUG] Parsing Docker config file")
-	confs, credsStore, err := parseDockerConfig(r)
+	configFile, err := parseDockerConfig(r)
 	if err != nil {
 		return nil, err
 	}
 
-	log.Printf("[DEBUG] Found Docker configs '%v'", confs)
-	auth, err = convertDockerConfigToAuthConfigs(confs, credsStore)
+	log.Printf("[DEBUG] Found Docker configs for registries %v", dockerConfigRegistries(configFile))
+	auth, err = convertDockerConfigToAuthConfigs(configFile)
 	if err != nil {
 		return nil, err
 	}
 	return auth, nil
 }
 
-// parseDockerConfig parses the docker config file for auths
-func parseDockerConfig(r io.Reader) (map[string]dockerConfig, string, error) {
+// parseDockerConfig parses the docker config file for auths, falling back to
+// the legacy .dockercfg format
+func parseDockerConfig(r io.Reader) (*dockerConfigFile, error) {
 	buf := new(bytes.Buffer)
 	buf.ReadFrom(r)
 	byteData := buf.Bytes()
 
-	confsWrapper := struct {
-		Auths      map[string]dockerConfig `json:"auths"`
-		CredsStore string                  `json:"credsStore,omitempty"`
-	}{}
-	if err := json.Unmarshal(byteData, &confsWrapper); err == nil {
-		if len(confsWrapper.Auths) > 0 {
-			return confsWrapper.Auths, confsWrapper.CredsStore, nil
+	configFile := &dockerConfigFile{}
+	if err := json.Unmarshal(byteData, configFile); err == nil {
+		if len(configFile.Auths) > 0 || len(configFile.CredHelpers) > 0 || configFile.CredsStore != "" {
+			return configFile, nil
 		}
 	}
 
 	var confs map[string]dockerConfig
 	if err := json.Unmarshal(byteData, &confs); err != nil {
-		return nil, "", err
+		return nil, err
 	}
-	return confs, "", nil
+	return &dockerConfigFile{Auths: confs}, nil
 }
 
-// convertDockerConfigToAuthConfigs converts a dockerConfigs map to a AuthConfigs object.
-func convertDockerConfigToAuthConfigs(confs map[string]dockerConfig, credsStore string) (*AuthConfigs, error) {
+// dockerConfigRegistries lists the registries of the config file, without
+// their credentials, for logging
+func dockerConfigRegistries(configFile *dockerConfigFile) []string {
+	registries := []string{}
+	for registryAddress := range configFile.Auths {
+		registries = append(registries, registryAddress)
+	}
+	for registryAddress := range configFile.CredHelpers {
+		if _, ok := configFile.Auths[registryAddress]; !ok {
+			registries = append(registries, registryAddress)
+		}
+	}
+	sort.Strings(registries)
+	return registries
+}
+
+// convertDockerConfigToAuthConfigs converts a docker config file to a AuthConfigs object.
+func convertDockerConfigToAuthConfigs(configFile *dockerConfigFile) (*AuthConfigs, error) {
 	c := &AuthConfigs{
 		Configs: make(map[string]types.AuthConfig),
 	}
-	for registryAddress, conf := range confs {
-		if conf.Auth == "" {
-			authFromKeyChain, err := getCredentialsFromOSKeychain(registryAddress, credsStore)
+	for registryAddress, conf := range configFile.Auths {
+		if _, ok := configFile.CredHelpers[registryAddress]; ok {
+			continue
+		}
+		if conf.Auth == "" && conf.Username == "" && conf.IdentityToken == "" {
+			authFromKeyChain, err := getCredentialsFromOSKeychain(registryAddress, configFile.CredsStore)
 			if err != nil {
-				return nil, err
+				log.Printf("[WARN] Unable to get the credentials of registry %s from credential store %s, skipping it: %s", registryAddress, configFile.CredsStore, err)
+				continue
 			}
 			c.Configs[registryAddress] = authFromKeyChain
 			continue
 		}
-		data, err := base64.StdEncoding.DecodeString(conf.Auth)
-		if err != nil {
-			return nil, err
-		}
-		userpass := strings.SplitN(string(data), ":", 2)
-		if len(userpass) != 2 {
-			return nil, ErrCannotParseDockercfg
-		}
-		c.Configs[registryAddress] = types.AuthConfig{
+
+		authConfig := types.AuthConfig{
 			Email:         conf.Email,
-			Username:      userpass[0],
-			Password:      userpass[1],
+			Username:      conf.Username,
+			Password:      conf.Password,
 			ServerAddress: registryAddress,
 			Auth:          conf.Auth,
+			IdentityToken: conf.IdentityToken,
+		}
+		if conf.Auth != "" {
+			data, err := base64.StdEncoding.DecodeString(conf.Auth)
+			if err != nil {
+				return nil, err
+			}
+			userpass := strings.SplitN(string(data), ":", 2)
+			if len(userpass) != 2 {
+				return nil, ErrCannotParseDockercfg
+			}
+			authConfig.Username = userpass[0]
+			authConfig.Password = userpass[1]
 		}
+		c.Configs[registryAddress] = authConfig
+	}
+
+	// credential helpers of a registry take precedence over the credentials
+	// store and the auths, as they do for the docker CLI. A failing helper
+	// only leaves its own registry without credentials.
+	for registryAddress, credHelper := range configFile.CredHelpers {
+		authFromKeyChain, err := getCredentialsFromOSKeychain(registryAddress, credHelper)
+		if err != nil {
+			log.Printf("[WARN] Unable to get the credentials of registry %s from credential helper %s, skipping it: %s", registryAddress, credHelper, err)
+			continue
+		}
+		c.Configs[registryAddress] = authFromKeyChain
 	}
 	return c, nil
 }
 
+// loadDefaultAuthConfigs loads the credentials the docker CLI uses, from the
+// config.json file in the DOCKER_CONFIG directory or in ~/.docker. The
+// configurations are keyed by normalized registry address. A missing config
+// file yields no credentials.
+func loadDefaultAuthConfigs() (*AuthConfigs, error) {
+	authConfigs := &AuthConfigs{
+		Configs: make(map[string]types.AuthConfig),
+	}
+
+	filePath, err := defaultDockerConfigFilePath()
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := os.Open(filePath)
+	if os.IsNotExist(err) {
+		log.Printf("[DEBUG] No Docker config file found at %s", filePath)
+		return authConfigs, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error opening docker config file: %v", err)
+	}
+	defer r.Close()
+
+	fileAuthConfigs, err := newAuthConfigurations(r)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing docker config file %s: %v", filePath, err)
+	}
+
+	for registryAddress, authConfig := range fileAuthConfigs.Configs {
+		address := normalizeDockerConfigAddress(registryAddress)
+		authConfig.ServerAddress = address
+		authConfigs.Configs[address] = authConfig
+	}
+	return authConfigs, nil
+}
+
+// defaultDockerConfigFilePath returns the path of the config file of the
+// docker CLI. DOCKER_CONFIG is the directory of the config file, the path of
+// the file itself is accepted as well.
+func defaultDockerConfigFilePath() (string, error) {
+	if dockerConfig := os.Getenv("DOCKER_CONFIG"); dockerConfig != "" {
+		if info, err := os.Stat(dockerConfig); err == nil && !info.IsDir() {
+			return dockerConfig, nil
+		}
+		return filepath.Join(dockerConfig, "config.json"), nil
+	}
+
+	usr, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(usr.HomeDir, ".docker", "config.json"), nil
+}
+
+// normalizeDockerConfigAddress maps a registry key of the docker config file
+// to the address the credentials are looked up by. Keys of the Docker Hub
+// such as https://index.docker.io/v1/ are mapped to the address used for
+// images without a registry.
+func normalizeDockerConfigAddress(registryAddress string) string {
+	address := strings.TrimPrefix(strings.TrimPrefix(registryAddress, "https://"), "http://")
+	if index := strings.Index(address, "/"); index != -1 {
+		address = address[:index]
+	}
+
+	switch address {
+	case "index.docker.io", "docker.io", "registry-1.docker.io", "registry.hub.docker.com":
+		return "https://registry.hub.docker.com"
+	}
+	return normalizeRegistryAddress(address)
+}
+
 // getCredentialsFromOSKeychain get config from system specific keychains
 func getCredentialsFromOSKeychain(registryAddress string, credsStore string) (types.AuthConfig, error) {
 	authConfig := types.AuthConfig{}
@@ -317,9 +525,15 @@ func getCredentialsFromOSKeychain(registryAddress string, credsStore string) (ty
 	if err != nil {
 		return authConfig, err
 	}
+	authConfig.ServerAddress = registryAddress
+	// helpers return the identity token of a registry as the secret of the
+	// <token> user
+	if credentials.Username == identityTokenUsername {
+		authConfig.IdentityToken = credentials.Secret
+		return authConfig, nil
+	}
 	authConfig.Username = credentials.Username
 	authConfig.Password = credentials.Secret
-	authConfig.ServerAddress = registryAddress
 	authConfig.Auth = base64.StdEncoding.EncodeToString([]byte(credentials.Username + ":" + credentials.Secret))
 	return authConfig, nil
 }
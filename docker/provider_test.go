@@ -1,7 +1,12 @@
 package docker
 
 import (
+	"io/ioutil"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/hashicorp/terraform/helper/schema"
@@ -47,3 +52,127 @@ func testAccPreCheck(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestLoadDefaultAuthConfigs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tf-docker-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv("DOCKER_CONFIG", os.Getenv("DOCKER_CONFIG"))
+	os.Setenv("DOCKER_CONFIG", dir)
+
+	authConfigs, err := loadDefaultAuthConfigs()
+	if err != nil {
+		t.Fatalf("a missing config file must not fail: %s", err)
+	}
+	if len(authConfigs.Configs) != 0 {
+		t.Fatalf("expected no credentials, got %v", authConfigs.Configs)
+	}
+
+	config := `{
+	"auths": {
+		"127.0.0.1:15000": {"auth": "dGVzdHVzZXI6dGVzdHB3ZA=="},
+		"https://index.docker.io/v1/": {"auth": "aHVidXNlcjpodWJwd2Q="}
+	}
+}`
+	if err := ioutil.WriteFile(filepath.Join(dir, "config.json"), []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	authConfigs, err = loadDefaultAuthConfigs()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if auth := authConfigs.Configs["https://127.0.0.1:15000"]; auth.Username != "testuser" || auth.Password != "testpwd" {
+		t.Fatalf("expected the credentials of 127.0.0.1:15000, got %+v", auth)
+	}
+	if auth := getAuthConfigForImage("alpine", authConfigs); auth.Username != "hubuser" || auth.Password != "hubpwd" {
+		t.Fatalf("expected the credentials of the Docker Hub, got %+v", auth)
+	}
+}
+
+func TestConvertDockerConfigCredHelpers(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake credential helper is a shell script")
+	}
+
+	dir, err := ioutil.TempDir("", "tf-docker-credential-helper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	helper := `#!/bin/sh
+read registry
+if [ "$registry" = "token.example.com" ]; then
+	echo "{\"ServerURL\":\"$registry\",\"Username\":\"<token>\",\"Secret\":\"refresh-token\"}"
+else
+	echo "{\"ServerURL\":\"$registry\",\"Username\":\"helperuser\",\"Secret\":\"helperpwd\"}"
+fi
+`
+	if err := ioutil.WriteFile(filepath.Join(dir, "docker-credential-tftest"), []byte(helper), 0700); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	configFile, err := parseDockerConfig(strings.NewReader(`{
+	"auths": {
+		"127.0.0.1:15000": {"auth": "dGVzdHVzZXI6dGVzdHB3ZA=="}
+	},
+	"credHelpers": {
+		"127.0.0.1:15000": "tftest",
+		"registry.example.com": "tftest",
+		"token.example.com": "tftest",
+		"missing.example.com": "tftest-missing"
+	}
+}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	authConfigs, err := convertDockerConfigToAuthConfigs(configFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, registry := range []string{"127.0.0.1:15000", "registry.example.com"} {
+		if auth := authConfigs.Configs[registry]; auth.Username != "helperuser" || auth.Password != "helperpwd" {
+			t.Fatalf("expected the credentials of the helper for %s, got %+v", registry, auth)
+		}
+	}
+	if auth := authConfigs.Configs["token.example.com"]; auth.IdentityToken != "refresh-token" || auth.Username != "" || auth.Password != "" {
+		t.Fatalf("expected the identity token of the helper, got %+v", auth)
+	}
+	// a failing helper only skips its own registry
+	if auth, ok := authConfigs.Configs["missing.example.com"]; ok {
+		t.Fatalf("expected no credentials for the registry of the missing helper, got %+v", auth)
+	}
+}
+
+func TestConvertDockerConfigIdentityTokens(t *testing.T) {
+	configFile, err := parseDockerConfig(strings.NewReader(`{
+	"auths": {
+		"127.0.0.1:15000": {"username": "testuser", "password": "testpwd"},
+		"registry.example.com": {"identitytoken": "refresh-token"},
+		"tokenuser.example.com": {"auth": "MDAwMDAwMDAtMDAwMC0wMDAwLTAwMDAtMDAwMDAwMDAwMDAwOg==", "identitytoken": "refresh-token"}
+	}
+}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	authConfigs, err := convertDockerConfigToAuthConfigs(configFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if auth := authConfigs.Configs["127.0.0.1:15000"]; auth.Username != "testuser" || auth.Password != "testpwd" {
+		t.Fatalf("expected the username and password of 127.0.0.1:15000, got %+v", auth)
+	}
+	if auth := authConfigs.Configs["registry.example.com"]; auth.IdentityToken != "refresh-token" || auth.Username != "" {
+		t.Fatalf("expected the identity token of registry.example.com, got %+v", auth)
+	}
+	if auth := authConfigs.Configs["tokenuser.example.com"]; auth.IdentityToken != "refresh-token" || auth.Username != "00000000-0000-0000-0000-000000000000" {
+		t.Fatalf("expected the identity token and username of tokenuser.example.com, got %+v", auth)
+	}
+}
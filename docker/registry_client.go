@@ -0,0 +1,441 @@
+package docker
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// registryOptions are the options of the connection to a registry, given in
+// its registry_auth block
+type registryOptions struct {
+	Scheme             string
+	CAMaterial         string
+	InsecureSkipVerify bool
+}
+
+// registryConnection holds what is needed to send requests to the API of a
+// registry
+type registryConnection struct {
+	client   *http.Client
+	baseURL  string
+	username string
+	password string
+	// identityToken is the OAuth2 refresh token of registries which are
+	// logged in to with an identity token instead of a password
+	identityToken string
+	tokens        *registryTokenCache
+}
+
+// newRegistryConnection returns the connection to the registry, using the
+// credentials and options configured for it and the token cache of the
+// provider
+func newRegistryConnection(registry string, providerConfig *ProviderConfig) (*registryConnection, error) {
+	authConfigs := providerConfig.AuthConfigs
+	options := registryOptions{}
+	if authConfigs != nil {
+		if registryOptions, ok := authConfigs.Registries[normalizeRegistryAddress(registry)]; ok {
+			options = registryOptions
+		}
+	}
+
+	client, err := registryHTTPClient(options)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating client for registry %s: %s", registry, err)
+	}
+
+	scheme := options.Scheme
+	if scheme == "" {
+		scheme = "https"
+	}
+
+	credentials := getRegistryCredentials(registry, authConfigs)
+	return &registryConnection{
+		client:        client,
+		baseURL:       scheme + "://" + registry,
+		username:      credentials.Username,
+		password:      credentials.Password,
+		identityToken: credentials.IdentityToken,
+		tokens:        providerConfig.RegistryTokens,
+	}, nil
+}
+
+// registryHTTPClient returns a dedicated client to talk to a registry, which
+// trusts the given CA certificates in addition to the ones of the system
+func registryHTTPClient(options registryOptions) (*http.Client, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: options.InsecureSkipVerify,
+	}
+
+	if options.CAMaterial != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM([]byte(options.CAMaterial)) {
+			return nil, errors.New("Unable to parse the CA certificates of ca_material")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport}, nil
+}
+
+// newRequest creates a request for the given path of the registry API
+func (r *registryConnection) newRequest(method, path string) (*http.Request, error) {
+	req, err := http.NewRequest(method, r.baseURL+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating registry request: %s", err)
+	}
+	return req, nil
+}
+
+// newRequestWithBody creates a request with a body for the given location,
+// either a path of the registry API or a URL as returned in the Location
+// header of the registry
+func (r *registryConnection) newRequestWithBody(method, location string, body io.Reader) (*http.Request, error) {
+	base, err := url.Parse(r.baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing registry URL: %s", err)
+	}
+	reference, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing registry location %s: %s", location, err)
+	}
+
+	req, err := http.NewRequest(method, base.ResolveReference(reference).String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating registry request: %s", err)
+	}
+	return req, nil
+}
+
+// do sends the request to the registry using basic auth. If the registry asks
+// for a bearer token instead, the token is requested from the announced realm
+// and the request is sent again with it. With an identity token, requests
+// are only authenticated by bearer tokens. Tokens are cached along with the
+// challenge they answered, so that later requests for the same repository
+// send the token right away. A request with a body is only sent again if
+// its body can be recreated with GetBody.
+func (r *registryConnection) do(req *http.Request) (*http.Response, error) {
+	if r.username != "" && r.identityToken == "" {
+		req.SetBasicAuth(r.username, r.password)
+	}
+
+	challengeKey := registryRequestAction(req.Method) + " " + r.baseURL + registryRequestRepository(req.URL.Path)
+	cachedToken := ""
+	if auth, ok := r.tokens.challenge(challengeKey); ok {
+		if token, ok := r.tokens.token(r.tokenKey(auth)); ok {
+			cachedToken = token
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Error during registry request: %s", err)
+	}
+
+	// Either OAuth is required or the basic auth creds were invalid
+	if resp.StatusCode != http.StatusUnauthorized || !strings.HasPrefix(resp.Header.Get("www-authenticate"), "Bearer") {
+		return resp, nil
+	}
+	auth := parseAuthHeader(resp.Header.Get("www-authenticate"))
+	resp.Body.Close()
+
+	tokenKey := r.tokenKey(auth)
+	r.tokens.setChallenge(challengeKey, auth)
+	token, ok := r.tokens.token(tokenKey)
+	if !ok || token == cachedToken {
+		// the cached token was rejected or there is none yet
+		token, err = r.fetchToken(auth, tokenKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if req.Body != nil {
+		if req.GetBody == nil {
+			return nil, fmt.Errorf("Unable to send the body of the request to %s again after authentication", req.URL.Path)
+		}
+		if req.Body, err = req.GetBody(); err != nil {
+			return nil, fmt.Errorf("Error recreating the body of the registry request: %s", err)
+		}
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err = r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Error during registry request: %s", err)
+	}
+	return resp, nil
+}
+
+// registryOAuthClientID identifies the provider to the realms it requests
+// tokens from with an identity token
+const registryOAuthClientID = "terraform-provider-docker"
+
+// fetchToken requests a bearer token from the realm of the challenge and
+// caches it
+func (r *registryConnection) fetchToken(auth map[string]string, tokenKey registryTokenKey) (string, error) {
+	tokenRequest, err := r.newTokenRequest(auth)
+	if err != nil {
+		return "", fmt.Errorf("Error creating registry request: %s", err)
+	}
+
+	tokenResponse, err := r.client.Do(tokenRequest)
+	if err != nil {
+		return "", fmt.Errorf("Error during registry request: %s", err)
+	}
+	defer tokenResponse.Body.Close()
+
+	if tokenResponse.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Got bad response from registry: %s", tokenResponse.Status)
+	}
+
+	body, err := ioutil.ReadAll(tokenResponse.Body)
+	if err != nil {
+		return "", fmt.Errorf("Error reading response body: %s", err)
+	}
+
+	token := &TokenResponse{}
+	err = json.Unmarshal(body, token)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing OAuth token response: %s", err)
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+
+	r.tokens.setToken(tokenKey, token)
+	return token.Token, nil
+}
+
+// newTokenRequest creates the request for a token answering the challenge.
+// An identity token is exchanged with the OAuth2 refresh token grant, other
+// tokens are requested with the username and password, if any.
+func (r *registryConnection) newTokenRequest(auth map[string]string) (*http.Request, error) {
+	// a challenge may ask for several scopes, e.g. to mount a blob from
+	// another repository, which are requested separately
+	scopes := strings.Fields(auth["scope"])
+
+	if r.identityToken != "" {
+		form := url.Values{}
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", r.identityToken)
+		form.Set("service", auth["service"])
+		form.Set("client_id", registryOAuthClientID)
+		form.Set("scope", strings.Join(scopes, " "))
+
+		tokenRequest, err := http.NewRequest("POST", auth["realm"], strings.NewReader(form.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		tokenRequest.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return tokenRequest, nil
+	}
+
+	params := url.Values{}
+	params.Set("service", auth["service"])
+	for _, scope := range scopes {
+		params.Add("scope", scope)
+	}
+	tokenRequest, err := http.NewRequest("GET", auth["realm"]+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if r.username != "" {
+		tokenRequest.SetBasicAuth(r.username, r.password)
+	}
+	return tokenRequest, nil
+}
+
+// tokenKey returns the key of the token answering the challenge with the
+// credentials of the connection
+func (r *registryConnection) tokenKey(auth map[string]string) registryTokenKey {
+	credentials := sha256.Sum256([]byte(r.username + ":" + r.password + ":" + r.identityToken))
+	return registryTokenKey{
+		realm:       auth["realm"],
+		service:     auth["service"],
+		scope:       auth["scope"],
+		credentials: hex.EncodeToString(credentials[:]),
+	}
+}
+
+// registryRequestRepository returns the part of the path of a registry API
+// request up to the repository name, e.g. /v2/library/alpine for
+// /v2/library/alpine/manifests/latest
+func registryRequestRepository(path string) string {
+	for _, endpoint := range []string{"/manifests/", "/blobs/", "/tags/"} {
+		if index := strings.LastIndex(path, endpoint); index != -1 {
+			return path[:index]
+		}
+	}
+	return path
+}
+
+// registryRequestAction returns the action of the registry token scope a
+// request with the given method needs, so that requests needing the same
+// scope share their challenge
+func registryRequestAction(method string) string {
+	switch method {
+	case "GET", "HEAD":
+		return "pull"
+	case "DELETE":
+		return "delete"
+	default:
+		return "push"
+	}
+}
+
+// TokenResponse is the answer of the token endpoint of a registry
+type TokenResponse struct {
+	Token       string `json:"token"`
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// registryTokenCache caches the bearer tokens of registries, along with the
+// challenges of the requests they answer. A nil cache caches nothing.
+type registryTokenCache struct {
+	mu         sync.Mutex
+	tokens     map[registryTokenKey]registryToken
+	challenges map[string]map[string]string
+}
+
+// registryTokenKey identifies the token of a realm, service and scope, for
+// the credentials the token was requested with
+type registryTokenKey struct {
+	realm       string
+	service     string
+	scope       string
+	credentials string
+}
+
+type registryToken struct {
+	token   string
+	expires time.Time
+}
+
+// Tokens are valid for 60 seconds unless the registry says otherwise, and are
+// not used during the last seconds of their lifetime, so that they don't
+// expire on their way to the registry
+const (
+	registryTokenDefaultExpiresIn = 60
+	registryTokenExpiryMargin     = 5 * time.Second
+)
+
+func newRegistryTokenCache() *registryTokenCache {
+	return &registryTokenCache{
+		tokens:     make(map[registryTokenKey]registryToken),
+		challenges: make(map[string]map[string]string),
+	}
+}
+
+func (c *registryTokenCache) token(key registryTokenKey) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	token, ok := c.tokens[key]
+	if !ok {
+		return "", false
+	}
+	if time.Now().After(token.expires) {
+		delete(c.tokens, key)
+		return "", false
+	}
+	return token.token, true
+}
+
+func (c *registryTokenCache) setToken(key registryTokenKey, tokenResponse *TokenResponse) {
+	if c == nil {
+		return
+	}
+
+	expiresIn := tokenResponse.ExpiresIn
+	if expiresIn < registryTokenDefaultExpiresIn {
+		expiresIn = registryTokenDefaultExpiresIn
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tokens[key] = registryToken{
+		token:   tokenResponse.Token,
+		expires: time.Now().Add(time.Duration(expiresIn)*time.Second - registryTokenExpiryMargin),
+	}
+}
+
+func (c *registryTokenCache) challenge(key string) (map[string]string, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	auth, ok := c.challenges[key]
+	return auth, ok
+}
+
+func (c *registryTokenCache) setChallenge(key string, auth map[string]string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.challenges[key] = auth
+}
+
+// Parses key/value pairs from a WWW-Authenticate header. Values may be quoted
+// and contain commas, like the actions of a scope, e.g.
+// Bearer realm="https://auth.example.com/token",scope="repository:app:pull,push"
+func parseAuthHeader(header string) map[string]string {
+	opts := make(map[string]string)
+	parts := strings.SplitN(header, " ", 2)
+	if len(parts) < 2 {
+		return opts
+	}
+
+	rest := parts[1]
+	for rest != "" {
+		vals := strings.SplitN(strings.TrimLeft(rest, ", "), "=", 2)
+		if len(vals) < 2 {
+			break
+		}
+		key, value := strings.TrimSpace(vals[0]), vals[1]
+
+		if strings.HasPrefix(value, "\"") {
+			value = value[1:]
+			end := strings.Index(value, "\"")
+			if end == -1 {
+				end = len(value)
+			}
+			opts[key] = value[:end]
+			rest = strings.TrimPrefix(value[end:], "\"")
+		} else {
+			end := strings.Index(value, ",")
+			if end == -1 {
+				end = len(value)
+			}
+			opts[key] = strings.TrimSpace(value[:end])
+			rest = value[end:]
+		}
+	}
+
+	return opts
+}
@@ -0,0 +1,233 @@
+package docker
+
+import (
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+func testRegistryManifestHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v2/tftest/image/manifests/latest" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", manifestV2MediaType)
+	w.Header().Set("Docker-Content-Digest", "sha256:1234")
+	w.Write([]byte("{}"))
+}
+
+func TestRegistryConnectionOptions(t *testing.T) {
+	tlsServer := httptest.NewTLSServer(http.HandlerFunc(testRegistryManifestHandler))
+	defer tlsServer.Close()
+	tlsRegistry := strings.TrimPrefix(tlsServer.URL, "https://")
+	caMaterial := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tlsServer.Certificate().Raw}))
+
+	httpServer := httptest.NewServer(http.HandlerFunc(testRegistryManifestHandler))
+	defer httpServer.Close()
+	httpRegistry := strings.TrimPrefix(httpServer.URL, "http://")
+
+	cases := []struct {
+		registry string
+		options  registryOptions
+		valid    bool
+	}{
+		{tlsRegistry, registryOptions{}, false},
+		{tlsRegistry, registryOptions{CAMaterial: caMaterial}, true},
+		{tlsRegistry, registryOptions{InsecureSkipVerify: true}, true},
+		{httpRegistry, registryOptions{}, false},
+		{httpRegistry, registryOptions{Scheme: "http"}, true},
+	}
+
+	for _, c := range cases {
+		providerConfig := &ProviderConfig{AuthConfigs: &AuthConfigs{
+			Registries: map[string]registryOptions{normalizeRegistryAddress(c.registry): c.options},
+		}}
+		registry, err := newRegistryConnection(c.registry, providerConfig)
+		if err != nil {
+			t.Fatalf("unexpected error creating the connection to %s: %s", c.registry, err)
+		}
+
+		digest, _, _, err := getImageManifest(registry, "tftest/image", "latest", []string{manifestV2MediaType})
+		if c.valid && (err != nil || digest != "sha256:1234") {
+			t.Errorf("expected the manifest of %s with options %+v, got %q, %v", c.registry, c.options, digest, err)
+		}
+		if !c.valid && err == nil {
+			t.Errorf("expected an error for %s with options %+v", c.registry, c.options)
+		}
+	}
+
+	if _, err := newRegistryConnection(tlsRegistry, &ProviderConfig{AuthConfigs: &AuthConfigs{
+		Registries: map[string]registryOptions{normalizeRegistryAddress(tlsRegistry): {CAMaterial: "not a certificate"}},
+	}}); err == nil {
+		t.Error("expected an error for invalid CA certificates")
+	}
+}
+
+func TestRegistryAuthToRegistryOptions(t *testing.T) {
+	auth := map[string]interface{}{
+		"address":              "http://localhost:5000",
+		"username":             "",
+		"password":             "",
+		"config_file":          "",
+		"ca_material":          "",
+		"insecure_skip_verify": false,
+		"scheme":               "https",
+	}
+
+	if options := registryAuthToRegistryOptions(auth); options.Scheme != "http" {
+		t.Errorf("expected the scheme of the address to be used, got %+v", options)
+	}
+	authConfig, err := registryAuthToAuthConfig(auth)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if authConfig.ServerAddress != normalizeRegistryAddress("localhost:5000") {
+		t.Errorf("expected plain HTTP registries to be keyed like the other registries, got %s", authConfig.ServerAddress)
+	}
+}
+
+func TestRegistryTokenCache(t *testing.T) {
+	var tokenRequests, challenges int
+	tokenCounter := 0
+	server := httptest.NewTLSServer(nil)
+	defer server.Close()
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			tokenRequests++
+			tokenCounter++
+			fmt.Fprintf(w, `{"token": "token-%d", "expires_in": 300}`, tokenCounter)
+		case strings.HasPrefix(r.URL.Path, "/v2/tftest/image/manifests/"):
+			if r.Header.Get("Authorization") != fmt.Sprintf("Bearer token-%d", tokenCounter) || tokenCounter == 0 {
+				challenges++
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry",scope="repository:tftest/image:pull"`, server.URL))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			testRegistryManifestHandler(w, r)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	})
+
+	providerConfig := &ProviderConfig{
+		AuthConfigs: &AuthConfigs{
+			Registries: map[string]registryOptions{
+				normalizeRegistryAddress(strings.TrimPrefix(server.URL, "https://")): {InsecureSkipVerify: true},
+			},
+		},
+		RegistryTokens: newRegistryTokenCache(),
+	}
+
+	getManifest := func() {
+		registry, err := newRegistryConnection(strings.TrimPrefix(server.URL, "https://"), providerConfig)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if _, _, _, err := getImageManifest(registry, "tftest/image", "latest", []string{manifestV2MediaType}); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		getManifest()
+	}
+	if tokenRequests != 1 || challenges != 1 {
+		t.Fatalf("expected 1 token request and 1 challenge, got %d and %d", tokenRequests, challenges)
+	}
+
+	// a rejected token is replaced
+	tokenCounter++
+	getManifest()
+	if tokenRequests != 2 || challenges != 2 {
+		t.Fatalf("expected a new token for a rejected token, got %d token requests and %d challenges", tokenRequests, challenges)
+	}
+
+	// an expired token is not used
+	for key, token := range providerConfig.RegistryTokens.tokens {
+		token.expires = time.Now().Add(-time.Second)
+		providerConfig.RegistryTokens.tokens[key] = token
+	}
+	getManifest()
+	if tokenRequests != 3 {
+		t.Fatalf("expected a new token for an expired token, got %d token requests", tokenRequests)
+	}
+}
+
+func TestRegistryIdentityToken(t *testing.T) {
+	server := httptest.NewTLSServer(nil)
+	defer server.Close()
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			r.ParseForm()
+			if r.Method != "POST" || r.PostForm.Get("grant_type") != "refresh_token" || r.PostForm.Get("refresh_token") != "refresh-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if r.PostForm.Get("service") != "registry" || r.PostForm.Get("scope") != "repository:tftest/image:pull" || r.PostForm.Get("client_id") == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"access_token": "access-token", "expires_in": 300}`)
+		case strings.HasPrefix(r.URL.Path, "/v2/tftest/image/manifests/"):
+			if _, _, ok := r.BasicAuth(); ok {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if r.Header.Get("Authorization") != "Bearer access-token" {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry",scope="repository:tftest/image:pull"`, server.URL))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			testRegistryManifestHandler(w, r)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	})
+
+	address := strings.TrimPrefix(server.URL, "https://")
+	providerConfig := &ProviderConfig{AuthConfigs: &AuthConfigs{
+		Configs: map[string]types.AuthConfig{
+			normalizeRegistryAddress(address): {Username: "00000000-0000-0000-0000-000000000000", IdentityToken: "refresh-token"},
+		},
+		Registries: map[string]registryOptions{normalizeRegistryAddress(address): {InsecureSkipVerify: true}},
+	}}
+
+	registry, err := newRegistryConnection(address, providerConfig)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	digest, _, _, err := getImageManifest(registry, "tftest/image", "latest", []string{manifestV2MediaType})
+	if err != nil || digest != "sha256:1234" {
+		t.Fatalf("expected the manifest to be fetched with the identity token, got %q, %v", digest, err)
+	}
+}
+
+func TestParseAuthHeader(t *testing.T) {
+	cases := map[string]map[string]string{
+		`Bearer realm="https://auth.example.com/token",service="registry.example.com",scope="repository:tftest/image:pull"`: {
+			"realm": "https://auth.example.com/token", "service": "registry.example.com", "scope": "repository:tftest/image:pull",
+		},
+		`Bearer realm="https://auth.example.com/token",scope="repository:tftest/image:pull,push repository:tftest/source:pull"`: {
+			"realm": "https://auth.example.com/token", "scope": "repository:tftest/image:pull,push repository:tftest/source:pull",
+		},
+		`Bearer realm=https://auth.example.com/token, service=registry`: {
+			"realm": "https://auth.example.com/token", "service": "registry",
+		},
+		`Bearer`: {},
+	}
+
+	for header, expected := range cases {
+		if opts := parseAuthHeader(header); !reflect.DeepEqual(opts, expected) {
+			t.Errorf("expected %v for %q, got %v", expected, header, opts)
+		}
+	}
+}
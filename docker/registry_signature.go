@@ -0,0 +1,213 @@
+package docker
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+)
+
+// Signatures are stored in the registry like cosign stores them: as an image
+// in the repository of the signed image, tagged after the digest of the
+// signed manifest, e.g. sha256-<hex>.sig. Its layers are the signed payloads,
+// the signatures are in their annotations.
+const (
+	cosignSignatureTagSuffix   = ".sig"
+	cosignSignatureAnnotation  = "dev.cosignproject.cosign/signature"
+	cosignSignaturePayloadType = "cosign container image signature"
+)
+
+// cosignSignaturePayload is the part of a signed payload naming the signed
+// manifest
+type cosignSignaturePayload struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+}
+
+// parseSignaturePublicKey parses a PEM-encoded ECDSA, RSA or Ed25519 public key
+func parseSignaturePublicKey(publicKeyPEM string) (crypto.PublicKey, error) {
+	block, _ := pem.Decode([]byte(publicKeyPEM))
+	if block == nil {
+		return nil, errors.New("No PEM-encoded public key found")
+	}
+
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing public key: %s", err)
+	}
+
+	switch publicKey.(type) {
+	case *ecdsa.PublicKey, *rsa.PublicKey, ed25519.PublicKey:
+		return publicKey, nil
+	default:
+		return nil, fmt.Errorf("Unsupported type of public key: %T", publicKey)
+	}
+}
+
+// verifyImageSignature verifies that the manifest the image name refers to in
+// its registry is signed with the public key, and returns its digest.
+func verifyImageSignature(name, publicKeyPEM string, providerConfig *ProviderConfig) (string, error) {
+	publicKey, err := parseSignaturePublicKey(publicKeyPEM)
+	if err != nil {
+		return "", err
+	}
+
+	pullOpts := parseRegistryImageOptions(name)
+	registry, err := newRegistryConnection(pullOpts.Registry, providerConfig)
+	if err != nil {
+		return "", err
+	}
+
+	accept := []string{manifestListMediaType, ociIndexMediaType, manifestV2MediaType, ociManifestMediaType}
+	_, _, body, err := getImageManifest(registry, pullOpts.Repository, pullOpts.Tag, accept)
+	if err != nil {
+		return "", err
+	}
+
+	// the digest of what was served is verified, not what the registry says
+	sum := sha256.Sum256(body)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	if strings.HasPrefix(pullOpts.Tag, "sha256:") && pullOpts.Tag != digest {
+		return "", fmt.Errorf("Content of manifest %s does not match its digest", pullOpts.Tag)
+	}
+
+	if err := verifyRegistryImageSignature(registry, pullOpts.Repository, digest, publicKey); err != nil {
+		return "", err
+	}
+	return digest, nil
+}
+
+// verifyLocalImageSignature verifies that one of the digests the local image
+// has in the repository of the image name is signed with the public key.
+func verifyLocalImageSignature(image *types.ImageInspect, name, publicKeyPEM string, providerConfig *ProviderConfig) error {
+	publicKey, err := parseSignaturePublicKey(publicKeyPEM)
+	if err != nil {
+		return err
+	}
+
+	pullOpts := parseRegistryImageOptions(name)
+	registry, err := newRegistryConnection(pullOpts.Registry, providerConfig)
+	if err != nil {
+		return err
+	}
+
+	err = fmt.Errorf("The local image has no digest in repository %s to verify", pullOpts.Repository)
+	for _, repoDigest := range image.RepoDigests {
+		parts := strings.SplitN(repoDigest, "@", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		repoOpts := parseRegistryImageOptions(parts[0])
+		if repoOpts.Registry != pullOpts.Registry || repoOpts.Repository != pullOpts.Repository {
+			continue
+		}
+
+		if err = verifyRegistryImageSignature(registry, pullOpts.Repository, parts[1], publicKey); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// verifyRegistryImageSignature verifies that one of the signatures stored in
+// the repository for the manifest with the given digest is valid for the
+// public key
+func verifyRegistryImageSignature(registry *registryConnection, image, digest string, publicKey crypto.PublicKey) error {
+	signatureTag := strings.Replace(digest, ":", "-", 1) + cosignSignatureTagSuffix
+	_, _, body, err := getImageManifest(registry, image, signatureTag, []string{ociManifestMediaType, manifestV2MediaType})
+	if err == errRegistryImageNotFound {
+		return fmt.Errorf("No signature found for %s@%s", image, digest)
+	}
+	if err != nil {
+		return fmt.Errorf("Error fetching the signatures of %s@%s: %s", image, digest, err)
+	}
+
+	manifest := &registryManifest{}
+	if err := json.Unmarshal(body, manifest); err != nil {
+		return fmt.Errorf("Error parsing signature manifest: %s", err)
+	}
+
+	err = fmt.Errorf("No signature found for %s@%s", image, digest)
+	for _, layer := range manifest.Layers {
+		signature, ok := layer.Annotations[cosignSignatureAnnotation]
+		if !ok {
+			continue
+		}
+
+		if err = verifyRegistrySignatureLayer(registry, image, digest, layer.Digest, signature, publicKey); err == nil {
+			log.Printf("[DEBUG] Verified signature %s of %s@%s", layer.Digest, image, digest)
+			return nil
+		}
+		log.Printf("[DEBUG] Signature %s of %s@%s is not valid: %s", layer.Digest, image, digest, err)
+	}
+	return err
+}
+
+// verifyRegistrySignatureLayer verifies the signature of the payload stored
+// in the given blob, and that the payload names the manifest digest
+func verifyRegistrySignatureLayer(registry *registryConnection, image, digest, payloadDigest, signature string, publicKey crypto.PublicKey) error {
+	rawSignature, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("Error decoding signature: %s", err)
+	}
+
+	payload, err := getRegistryBlob(registry, image, payloadDigest)
+	if err != nil {
+		return err
+	}
+
+	if err := verifySignature(publicKey, payload, rawSignature); err != nil {
+		return err
+	}
+
+	signedPayload := &cosignSignaturePayload{}
+	if err := json.Unmarshal(payload, signedPayload); err != nil {
+		return fmt.Errorf("Error parsing signed payload: %s", err)
+	}
+	if signedPayload.Critical.Type != cosignSignaturePayloadType {
+		return fmt.Errorf("Unsupported type of signed payload: %s", signedPayload.Critical.Type)
+	}
+	if signedPayload.Critical.Image.DockerManifestDigest != digest {
+		return fmt.Errorf("The signature is for manifest %s", signedPayload.Critical.Image.DockerManifestDigest)
+	}
+	return nil
+}
+
+// verifySignature verifies the signature of the payload, which is signed as
+// a whole with Ed25519 keys and by its SHA-256 hash with the other keys
+func verifySignature(publicKey crypto.PublicKey, payload, signature []byte) error {
+	hash := sha256.Sum256(payload)
+
+	valid := false
+	switch key := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		valid = ecdsa.VerifyASN1(key, hash[:], signature)
+	case *rsa.PublicKey:
+		valid = rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], signature) == nil
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(key, payload, signature)
+	default:
+		return fmt.Errorf("Unsupported type of public key: %T", publicKey)
+	}
+
+	if !valid {
+		return errors.New("The signature does not match the public key")
+	}
+	return nil
+}
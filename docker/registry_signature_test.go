@@ -0,0 +1,170 @@
+package docker
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+// testSignaturePublicKey is a public key none of the test images are signed with
+const testSignaturePublicKey = `-----BEGIN PUBLIC KEY-----
+MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAECEHVeXHI7RgWq/FKA2VrHSkqeYNV
+Fx8KxCk7X1Z8PgpNwColYpT+ReWGWARt9PBNOcv/MIfoDxlF8wATrKRunA==
+-----END PUBLIC KEY-----`
+
+// testSigner signs images in a test registry like cosign does
+type testSigner struct {
+	publicKey string
+	sign      func(payload []byte) []byte
+}
+
+func newTestSigner(t *testing.T, ed25519Key bool) *testSigner {
+	var publicKey crypto.PublicKey
+	var sign func(payload []byte) []byte
+	if ed25519Key {
+		public, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		publicKey = public
+		sign = func(payload []byte) []byte {
+			return ed25519.Sign(private, payload)
+		}
+	} else {
+		private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		publicKey = &private.PublicKey
+		sign = func(payload []byte) []byte {
+			hash := sha256.Sum256(payload)
+			signature, err := ecdsa.SignASN1(rand.Reader, private, hash[:])
+			if err != nil {
+				t.Fatal(err)
+			}
+			return signature
+		}
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testSigner{
+		publicKey: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+		sign:      sign,
+	}
+}
+
+// signImage adds a signature of the payload naming the signed digest to the
+// signatures of the image with the given digest
+func (s *testSigner) signImage(registry *testRegistry, repository, digest, signedDigest string) {
+	signatureTag := strings.Replace(digest, ":", "-", 1) + ".sig"
+	signatures := registryManifest{}
+	if manifest, ok := registry.manifests[repository+":"+signatureTag]; ok {
+		json.Unmarshal(manifest.content, &signatures)
+	}
+
+	payload := []byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":"%s"},"image":{"docker-manifest-digest":"%s"},"type":"cosign container image signature"},"optional":null}`, repository, signedDigest))
+	layer := registry.addBlob(repository, payload)
+	layer.MediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+	layer.Annotations = map[string]string{cosignSignatureAnnotation: base64.StdEncoding.EncodeToString(s.sign(payload))}
+
+	config := registry.addBlob(repository, []byte("{}"))
+	config.MediaType = "application/vnd.oci.image.config.v1+json"
+	registry.addManifest(repository, signatureTag, ociManifestMediaType, registryManifest{
+		MediaType: ociManifestMediaType,
+		Config:    config,
+		Layers:    append(signatures.Layers, layer),
+	})
+}
+
+func TestVerifyImageSignature(t *testing.T) {
+	registry := newTestRegistry("")
+	defer registry.server.Close()
+	address := strings.TrimPrefix(registry.server.URL, "https://")
+	providerConfig := &ProviderConfig{AuthConfigs: &AuthConfigs{
+		Registries: map[string]registryOptions{normalizeRegistryAddress(address): {InsecureSkipVerify: true}},
+	}}
+
+	ecdsaSigner := newTestSigner(t, false)
+	ed25519Signer := newTestSigner(t, true)
+	otherSigner := newTestSigner(t, false)
+
+	digests := map[string]string{}
+	for _, tag := range []string{"signed", "ed25519", "wrong-key", "wrong-digest", "unsigned"} {
+		layer := registry.addBlob("tftest/image", []byte(tag))
+		digests[tag] = registry.addManifest("tftest/image", tag, manifestV2MediaType, registryManifest{
+			MediaType: manifestV2MediaType,
+			Config:    layer,
+			Layers:    []registryManifestDescriptor{layer},
+		})
+	}
+	// a valid signature of another key doesn't hide the one of the key
+	otherSigner.signImage(registry, "tftest/image", digests["signed"], digests["signed"])
+	ecdsaSigner.signImage(registry, "tftest/image", digests["signed"], digests["signed"])
+	ed25519Signer.signImage(registry, "tftest/image", digests["ed25519"], digests["ed25519"])
+	otherSigner.signImage(registry, "tftest/image", digests["wrong-key"], digests["wrong-key"])
+	ecdsaSigner.signImage(registry, "tftest/image", digests["wrong-digest"], digests["signed"])
+
+	cases := []struct {
+		tag       string
+		publicKey string
+		err       string
+	}{
+		{"signed", ecdsaSigner.publicKey, ""},
+		{"ed25519", ed25519Signer.publicKey, ""},
+		{"wrong-key", ecdsaSigner.publicKey, "The signature does not match the public key"},
+		{"wrong-digest", ecdsaSigner.publicKey, "The signature is for manifest " + digests["signed"]},
+		{"unsigned", ecdsaSigner.publicKey, "No signature found for tftest/image@" + digests["unsigned"]},
+	}
+
+	for _, c := range cases {
+		digest, err := verifyImageSignature(address+"/tftest/image:"+c.tag, c.publicKey, providerConfig)
+		if c.err == "" {
+			if err != nil || digest != digests[c.tag] {
+				t.Errorf("expected image %s to be verified with digest %s, got %q, %v", c.tag, digests[c.tag], digest, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("expected error %q for image %s, got %v", c.err, c.tag, err)
+		}
+	}
+
+	if _, err := verifyImageSignature(address+"/tftest/image@"+digests["signed"], ecdsaSigner.publicKey, providerConfig); err != nil {
+		t.Errorf("expected a digest reference to be verified, got %s", err)
+	}
+
+	// local images are verified by their digests in the repository
+	localImage := &types.ImageInspect{RepoDigests: []string{
+		"alpine@" + digests["unsigned"],
+		address + "/tftest/image@" + digests["unsigned"],
+		address + "/tftest/image@" + digests["signed"],
+	}}
+	if err := verifyLocalImageSignature(localImage, address+"/tftest/image:latest", ecdsaSigner.publicKey, providerConfig); err != nil {
+		t.Errorf("expected the local image to be verified, got %s", err)
+	}
+
+	localImage.RepoDigests = localImage.RepoDigests[:2]
+	if err := verifyLocalImageSignature(localImage, address+"/tftest/image:latest", ecdsaSigner.publicKey, providerConfig); err == nil {
+		t.Error("expected an error for an unsigned local image")
+	}
+
+	localImage.RepoDigests = nil
+	if err := verifyLocalImageSignature(localImage, address+"/tftest/image:latest", ecdsaSigner.publicKey, providerConfig); err == nil || !strings.Contains(err.Error(), "no digest in repository") {
+		t.Errorf("expected an error for a local image without digest, got %v", err)
+	}
+}
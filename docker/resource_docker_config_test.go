@@ -27,6 +27,11 @@ func TestAccDockerConfig_basic(t *testing.T) {
 					resource.TestCheckResourceAttr("docker_config.foo", "data", "Ymxhc2RzYmxhYmxhMTI0ZHNkd2VzZA=="),
 				),
 			},
+			{
+				ResourceName:      "docker_config.foo",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
 		},
 	})
 }
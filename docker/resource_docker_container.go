@@ -14,6 +14,9 @@ func resourceDockerContainer() *schema.Resource {
 		Delete:        resourceDockerContainerDelete,
 		MigrateState:  resourceDockerContainerMigrateState,
 		SchemaVersion: 1,
+		Importer: &schema.ResourceImporter{
+			State: resourceDockerContainerImportState,
+		},
 
 		Schema: map[string]*schema.Schema{
 			"name": {
@@ -153,7 +156,6 @@ func resourceDockerContainer() *schema.Resource {
 			"restart": {
 				Type:         schema.TypeString,
 				Optional:     true,
-				ForceNew:     true,
 				Default:      "no",
 				ValidateFunc: validateStringMatchesPattern(`^(no|on-failure|always|unless-stopped)$`),
 			},
@@ -161,7 +163,6 @@ func resourceDockerContainer() *schema.Resource {
 			"max_retry_count": {
 				Type:     schema.TypeInt,
 				Optional: true,
-				ForceNew: true,
 			},
 			"working_dir": &schema.Schema{
 				Type:     schema.TypeString,
@@ -537,28 +538,24 @@ func resourceDockerContainer() *schema.Resource {
 			"memory": {
 				Type:         schema.TypeInt,
 				Optional:     true,
-				ForceNew:     true,
 				ValidateFunc: validateIntegerGeqThan(0),
 			},
 
 			"memory_swap": {
 				Type:         schema.TypeInt,
 				Optional:     true,
-				ForceNew:     true,
 				ValidateFunc: validateIntegerGeqThan(-1),
 			},
 
 			"cpu_shares": {
 				Type:         schema.TypeInt,
 				Optional:     true,
-				ForceNew:     true,
 				ValidateFunc: validateIntegerGeqThan(0),
 			},
 
 			"cpu_set": {
 				Type:         schema.TypeString,
 				Optional:     true,
-				ForceNew:     true,
 				ValidateFunc: validateStringMatchesPattern(`^\d+([,-]\d+)*$`),
 			},
 
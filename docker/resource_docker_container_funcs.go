@@ -9,7 +9,7 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"sort"
+	"reflect"
 	"strconv"
 	"strings"
 	"time"
@@ -33,9 +33,8 @@ var (
 func resourceDockerContainerCreate(d *schema.ResourceData, meta interface{}) error {
 	var err error
 	client := meta.(*ProviderConfig).DockerClient
-	authConfigs := meta.(*ProviderConfig).AuthConfigs
 	image := d.Get("image").(string)
-	_, err = findImage(image, client, authConfigs)
+	_, err = findImage(image, "", meta.(*ProviderConfig))
 	if err != nil {
 		return fmt.Errorf("Unable to create container with image %s: %s", image, err)
 	}
@@ -529,6 +528,66 @@ func resourceDockerContainerRead(d *schema.ResourceData, meta interface{}) error
 		d.Set("exit_code", container.State.ExitCode)
 	}
 
+	// Read the attributes which can be updated in place
+	if container.HostConfig != nil {
+		d.Set("restart", container.HostConfig.RestartPolicy.Name)
+		d.Set("max_retry_count", container.HostConfig.RestartPolicy.MaximumRetryCount)
+		d.Set("memory", container.HostConfig.Memory/1024/1024)
+		d.Set("memory_swap", flattenContainerMemorySwap(container.HostConfig, d.Get("memory_swap").(int)))
+		d.Set("cpu_shares", container.HostConfig.CPUShares)
+		d.Set("cpu_set", container.HostConfig.CpusetCpus)
+	}
+
+	// The container inherits env, labels, command, entrypoint, user, volumes
+	// and healthcheck from its image, so the image defaults are filtered out
+	imageConfig := fetchDockerContainerImageConfig(container.Image, client)
+
+	// Read Container Config
+	if container.Config != nil {
+		if err := d.Set("env", flattenContainerEnv(container.Config.Env, imageConfig.Env, d.Get("env").(*schema.Set))); err != nil {
+			log.Printf("[WARN] failed to set env from API: %s", err)
+		}
+		if err := d.Set("labels", flattenContainerLabels(container.Config.Labels, imageConfig.Labels, d.Get("labels").(map[string]interface{}))); err != nil {
+			log.Printf("[WARN] failed to set labels from API: %s", err)
+		}
+		if _, ok := d.GetOk("command"); ok || !stringSlicesEqual(container.Config.Cmd, imageConfig.Cmd) {
+			d.Set("command", []string(container.Config.Cmd))
+		}
+		if _, ok := d.GetOk("entrypoint"); ok || !stringSlicesEqual(container.Config.Entrypoint, imageConfig.Entrypoint) {
+			d.Set("entrypoint", []string(container.Config.Entrypoint))
+		}
+		if _, ok := d.GetOk("user"); ok || container.Config.User != imageConfig.User {
+			d.Set("user", container.Config.User)
+		}
+		if _, ok := d.GetOk("healthcheck"); ok || !reflect.DeepEqual(container.Config.Healthcheck, imageConfig.Healthcheck) {
+			if err := d.Set("healthcheck", flattenServiceHealthcheck(container.Config.Healthcheck)); err != nil {
+				log.Printf("[WARN] failed to set healthcheck from API: %s", err)
+			}
+		}
+	}
+
+	// Read Host Config
+	if container.HostConfig != nil && container.Config != nil {
+		if err := d.Set("mounts", flattenContainerMounts(container.HostConfig.Mounts)); err != nil {
+			log.Printf("[WARN] failed to set mounts from API: %s", err)
+		}
+		if err := d.Set("volumes", flattenContainerVolumes(container.HostConfig, container.Config.Volumes, imageConfig.Volumes, d.Get("volumes").(*schema.Set))); err != nil {
+			log.Printf("[WARN] failed to set volumes from API: %s", err)
+		}
+		if err := d.Set("ulimit", flattenContainerUlimits(container.HostConfig.Ulimits)); err != nil {
+			log.Printf("[WARN] failed to set ulimits from API: %s", err)
+		}
+		if err := d.Set("devices", flattenContainerDevices(container.HostConfig.Devices, d.Get("devices").(*schema.Set))); err != nil {
+			log.Printf("[WARN] failed to set devices from API: %s", err)
+		}
+		if err := d.Set("capabilities", flattenContainerCapabilities(container.HostConfig.CapAdd, container.HostConfig.CapDrop)); err != nil {
+			log.Printf("[WARN] failed to set capabilities from API: %s", err)
+		}
+		d.Set("log_driver", container.HostConfig.LogConfig.Type)
+		d.Set("log_opts", flattenContainerLogOpts(container.HostConfig.LogConfig.Config, d.Get("log_opts").(map[string]interface{})))
+		d.Set("sysctls", container.HostConfig.Sysctls)
+	}
+
 	// Read Network Settings
 	if container.NetworkSettings != nil {
 		// TODO remove deprecated attributes in next major
@@ -558,8 +617,52 @@ func resourceDockerContainerRead(d *schema.ResourceData, meta interface{}) error
 }
 
 func resourceDockerContainerUpdate(d *schema.ResourceData, meta interface{}) error {
-	// TODO call resourceDockerContainerRead here
-	return nil
+	if !hasAnyChange(d, "restart", "max_retry_count", "cpu_shares", "memory", "cpu_set", "memory_swap") {
+		return resourceDockerContainerRead(d, meta)
+	}
+
+	updateConfig := container.UpdateConfig{
+		RestartPolicy: container.RestartPolicy{
+			Name:              d.Get("restart").(string),
+			MaximumRetryCount: d.Get("max_retry_count").(int),
+		},
+		Resources: container.Resources{
+			CPUShares:  int64(d.Get("cpu_shares").(int)),
+			CpusetCpus: d.Get("cpu_set").(string),
+		},
+	}
+
+	// The daemon leaves a limit of 0 unchanged, so removed limits are sent
+	// as unlimited and a removed swap limit falls back to the daemon default
+	// of twice the memory limit.
+	if d.HasChange("memory") || d.HasChange("memory_swap") {
+		memory := int64(d.Get("memory").(int)) * 1024 * 1024
+		if memory == 0 {
+			memory = -1
+		}
+		swap := int64(d.Get("memory_swap").(int))
+		switch {
+		case swap > 0:
+			swap = swap * 1024 * 1024
+		case swap == 0 && memory > 0:
+			swap = 2 * memory
+		case swap == 0:
+			swap = -1
+		}
+		updateConfig.Resources.Memory = memory
+		updateConfig.Resources.MemorySwap = swap
+	}
+
+	client := meta.(*ProviderConfig).DockerClient
+	updateBody, err := client.ContainerUpdate(context.Background(), d.Id(), updateConfig)
+	if err != nil {
+		return fmt.Errorf("Unable to update a container: %s", err)
+	}
+	if len(updateBody.Warnings) > 0 {
+		log.Printf("[INFO] Warnings while updating container '%s': %v", d.Id(), updateBody.Warnings)
+	}
+
+	return resourceDockerContainerRead(d, meta)
 }
 
 func resourceDockerContainerDelete(d *schema.ResourceData, meta interface{}) error {
@@ -604,65 +707,77 @@ func resourceDockerContainerDelete(d *schema.ResourceData, meta interface{}) err
 	return nil
 }
 
-// TODO extract to structures_container.go
-type byPortAndProtocol []string
+// resourceDockerContainerImportState looks up the container by its ID or name
+// and sets the attributes which are only known on creation. The remaining
+// attributes are populated by resourceDockerContainerRead.
+func resourceDockerContainerImportState(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	client := meta.(*ProviderConfig).DockerClient
 
-func (s byPortAndProtocol) Len() int {
-	return len(s)
-}
-func (s byPortAndProtocol) Swap(i, j int) {
-	s[i], s[j] = s[j], s[i]
-}
-func (s byPortAndProtocol) Less(i, j int) bool {
-	iSplit := strings.Split(string(s[i]), "/")
-	iPort, _ := strconv.Atoi(iSplit[0])
-	jSplit := strings.Split(string(s[j]), "/")
-	jPort, _ := strconv.Atoi(jSplit[0])
-	return iPort < jPort
-}
+	container, err := client.ContainerInspect(context.Background(), d.Id())
+	if err != nil {
+		return nil, fmt.Errorf("Error inspecting container %s: %s", d.Id(), err)
+	}
 
-func flattenContainerPorts(in nat.PortMap) []interface{} {
-	var out = make([]interface{}, 0)
+	d.SetId(container.ID)
+	d.Set("name", strings.TrimPrefix(container.Name, "/"))
+	d.Set("image", container.Image)
+	d.Set("start", true)
+	d.Set("attach", false)
+	d.Set("logs", false)
+	// A stopped container is left alone instead of being removed on the next refresh
+	d.Set("must_run", container.State.Running)
 
-	var internalPortKeys []string
-	for portAndProtocolKeys := range in {
-		internalPortKeys = append(internalPortKeys, string(portAndProtocolKeys))
+	if container.Config != nil {
+		if !strings.HasPrefix(container.ID, container.Config.Hostname) {
+			d.Set("hostname", container.Config.Hostname)
+		}
+		d.Set("domainname", container.Config.Domainname)
+		d.Set("working_dir", container.Config.WorkingDir)
 	}
-	sort.Sort(byPortAndProtocol(internalPortKeys))
 
-	for _, portKey := range internalPortKeys {
-		m := make(map[string]interface{})
+	if container.HostConfig != nil {
+		d.Set("rm", container.HostConfig.AutoRemove)
+		// the options are only read back as far as they are in the state
+		d.Set("log_opts", container.HostConfig.LogConfig.Config)
+		d.Set("privileged", container.HostConfig.Privileged)
+		d.Set("publish_all_ports", container.HostConfig.PublishAllPorts)
+		if !container.HostConfig.NetworkMode.IsDefault() {
+			d.Set("network_mode", string(container.HostConfig.NetworkMode))
+		}
+		if err := d.Set("links", flattenContainerLinks(container.HostConfig.Links)); err != nil {
+			log.Printf("[WARN] failed to set links from API: %s", err)
+		}
+		d.Set("dns", container.HostConfig.DNS)
+		d.Set("dns_opts", container.HostConfig.DNSOptions)
+		d.Set("dns_search", container.HostConfig.DNSSearch)
+		d.Set("tmpfs", container.HostConfig.Tmpfs)
+		d.Set("pid_mode", string(container.HostConfig.PidMode))
+		d.Set("userns_mode", string(container.HostConfig.UsernsMode))
+		if !container.HostConfig.IpcMode.IsPrivate() {
+			d.Set("ipc_mode", string(container.HostConfig.IpcMode))
+		}
+		if err := d.Set("host", flattenContainerExtraHosts(container.HostConfig.ExtraHosts)); err != nil {
+			log.Printf("[WARN] failed to set extra hosts from API: %s", err)
+		}
+	}
 
-		portBindings := in[nat.Port(portKey)]
-		for _, portBinding := range portBindings {
-			portProtocolSplit := strings.Split(string(portKey), "/")
-			convertedInternal, _ := strconv.Atoi(portProtocolSplit[0])
-			convertedExternal, _ := strconv.Atoi(portBinding.HostPort)
-			m["internal"] = convertedInternal
-			m["external"] = convertedExternal
-			m["ip"] = portBinding.HostIP
-			m["protocol"] = portProtocolSplit[1]
-			out = append(out, m)
+	if container.NetworkSettings != nil && container.HostConfig != nil {
+		if err := d.Set("networks_advanced", flattenContainerNetworksAdvanced(container.ID, container.HostConfig.NetworkMode, container.NetworkSettings.Networks)); err != nil {
+			log.Printf("[WARN] failed to set networks advanced from API: %s", err)
 		}
 	}
-	return out
+
+	return []*schema.ResourceData{d}, nil
 }
-func flattenContainerNetworks(in *types.NetworkSettings) []interface{} {
-	var out = make([]interface{}, 0)
-	if in == nil || in.Networks == nil || len(in.Networks) == 0 {
-		return out
-	}
-
-	networks := in.Networks
-	for networkName, networkData := range networks {
-		m := make(map[string]interface{})
-		m["network_name"] = networkName
-		m["ip_address"] = networkData.IPAddress
-		m["ip_prefix_length"] = networkData.IPPrefixLen
-		m["gateway"] = networkData.Gateway
-		out = append(out, m)
-	}
-	return out
+
+// hasAnyChange reports whether any of the given attributes has changed.
+func hasAnyChange(d *schema.ResourceData, keys ...string) bool {
+	for _, key := range keys {
+		if d.HasChange(key) {
+			return true
+		}
+	}
+	return false
 }
 
 // TODO move to separate flattener file
@@ -724,6 +839,20 @@ func fetchDockerContainer(ID string, client *client.Client) (*types.Container, e
 	return nil, nil
 }
 
+// fetchDockerContainerImageConfig returns the config of the image a container
+// was created from, or an empty config if the image is not available anymore.
+func fetchDockerContainerImageConfig(imageID string, client *client.Client) *container.Config {
+	image, _, err := client.ImageInspectWithRaw(context.Background(), imageID)
+	if err != nil {
+		log.Printf("[WARN] failed to inspect image %s: %s", imageID, err)
+		return &container.Config{}
+	}
+	if image.Config == nil {
+		return &container.Config{}
+	}
+	return image.Config
+}
+
 func portSetToDockerPorts(ports []interface{}) (map[nat.Port]struct{}, map[nat.Port][]nat.PortBinding) {
 	retExposedPorts := map[nat.Port]struct{}{}
 	retPortBindings := map[nat.Port][]nat.PortBinding{}
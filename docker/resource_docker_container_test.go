@@ -64,9 +64,87 @@ func TestAccDockerContainer_basic(t *testing.T) {
 					testAccContainerRunning("docker_container.foo", &c),
 				),
 			},
+			{
+				ResourceName:            "docker_container.foo",
+				ImportState:             true,
+				ImportStateVerify:       true,
+				ImportStateVerifyIgnore: []string{"attach", "logs", "must_run", "start", "rm", "container_logs", "destroy_grace_seconds"},
+			},
 		},
 	})
 }
+func TestAccDockerContainer_update(t *testing.T) {
+	var c types.ContainerJSON
+	var containerID string
+
+	testCheckUpdated := func(*terraform.State) error {
+		if c.ID != containerID {
+			return fmt.Errorf("Container was recreated instead of updated in place: %s != %s", c.ID, containerID)
+		}
+		if c.HostConfig.RestartPolicy.Name != "on-failure" {
+			return fmt.Errorf("Container has wrong restart policy: %s", c.HostConfig.RestartPolicy.Name)
+		}
+		if c.HostConfig.RestartPolicy.MaximumRetryCount != 3 {
+			return fmt.Errorf("Container has wrong restart policy max retry count: %d", c.HostConfig.RestartPolicy.MaximumRetryCount)
+		}
+		if c.HostConfig.Memory != (256 * 1024 * 1024) {
+			return fmt.Errorf("Container has wrong memory setting: %d", c.HostConfig.Memory)
+		}
+		if c.HostConfig.CPUShares != 64 {
+			return fmt.Errorf("Container has wrong cpu shares setting: %d", c.HostConfig.CPUShares)
+		}
+		return nil
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDockerContainerUpdateConfig,
+				Check: resource.ComposeTestCheckFunc(
+					testAccContainerRunning("docker_container.foo", &c),
+					func(*terraform.State) error {
+						containerID = c.ID
+						return nil
+					},
+					resource.TestCheckResourceAttr("docker_container.foo", "restart", "no"),
+					resource.TestCheckResourceAttr("docker_container.foo", "memory", "128"),
+					resource.TestCheckResourceAttr("docker_container.foo", "cpu_shares", "32"),
+				),
+			},
+			{
+				Config: testAccDockerContainerUpdatedConfig,
+				Check: resource.ComposeTestCheckFunc(
+					testAccContainerRunning("docker_container.foo", &c),
+					testCheckUpdated,
+					resource.TestCheckResourceAttr("docker_container.foo", "restart", "on-failure"),
+					resource.TestCheckResourceAttr("docker_container.foo", "max_retry_count", "3"),
+					resource.TestCheckResourceAttr("docker_container.foo", "memory", "256"),
+					resource.TestCheckResourceAttr("docker_container.foo", "cpu_shares", "64"),
+				),
+			},
+			{
+				Config: testAccDockerContainerUnlimitedConfig,
+				Check: resource.ComposeTestCheckFunc(
+					testAccContainerRunning("docker_container.foo", &c),
+					resource.TestCheckResourceAttr("docker_container.foo", "memory", "0"),
+					resource.TestCheckResourceAttr("docker_container.foo", "memory_swap", "0"),
+					func(*terraform.State) error {
+						if c.ID != containerID {
+							return fmt.Errorf("Container was recreated instead of updated in place: %s != %s", c.ID, containerID)
+						}
+						if c.HostConfig.Memory > 0 {
+							return fmt.Errorf("Container should have no memory limit but has %d", c.HostConfig.Memory)
+						}
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
 func TestAccDockerContainer_basic_network(t *testing.T) {
 	var c types.ContainerJSON
 	resource.Test(t, resource.TestCase{
@@ -471,6 +549,13 @@ func TestAccDockerContainer_customized(t *testing.T) {
 				Check: resource.ComposeTestCheckFunc(
 					testAccContainerRunning("docker_container.foo", &c),
 					testCheck,
+					resource.TestCheckResourceAttr("docker_container.foo", "labels.%", "2"),
+					resource.TestCheckResourceAttr("docker_container.foo", "labels.env", "prod"),
+					resource.TestCheckResourceAttr("docker_container.foo", "entrypoint.#", "3"),
+					resource.TestCheckResourceAttr("docker_container.foo", "user", "root:root"),
+					resource.TestCheckResourceAttr("docker_container.foo", "capabilities.#", "1"),
+					resource.TestCheckResourceAttr("docker_container.foo", "ulimit.#", "2"),
+					resource.TestCheckResourceAttr("docker_container.foo", "log_opts.max-size", "10m"),
 				),
 			},
 		},
@@ -1288,6 +1373,48 @@ resource "docker_container" "foo" {
 }
 `
 
+const testAccDockerContainerUpdateConfig = `
+resource "docker_image" "foo" {
+	name = "nginx:latest"
+}
+
+resource "docker_container" "foo" {
+	name = "tf-test"
+	image = "${docker_image.foo.latest}"
+	memory = 128
+	cpu_shares = 32
+}
+`
+
+const testAccDockerContainerUpdatedConfig = `
+resource "docker_image" "foo" {
+	name = "nginx:latest"
+}
+
+resource "docker_container" "foo" {
+	name = "tf-test"
+	image = "${docker_image.foo.latest}"
+	restart = "on-failure"
+	max_retry_count = 3
+	memory = 256
+	cpu_shares = 64
+}
+`
+
+const testAccDockerContainerUnlimitedConfig = `
+resource "docker_image" "foo" {
+	name = "nginx:latest"
+}
+
+resource "docker_container" "foo" {
+	name = "tf-test"
+	image = "${docker_image.foo.latest}"
+	restart = "on-failure"
+	max_retry_count = 3
+	cpu_shares = 64
+}
+`
+
 const testAccDockerContainerWith2BridgeNetworkConfig = `
 resource "docker_network" "tftest" {
   name = "tftest-contnw"
@@ -6,10 +6,14 @@ import (
 
 func resourceDockerImage() *schema.Resource {
 	return &schema.Resource{
-		Create: resourceDockerImageCreate,
-		Read:   resourceDockerImageRead,
-		Update: resourceDockerImageUpdate,
-		Delete: resourceDockerImageDelete,
+		Create:        resourceDockerImageCreate,
+		Read:          resourceDockerImageRead,
+		Update:        resourceDockerImageUpdate,
+		Delete:        resourceDockerImageDelete,
+		CustomizeDiff: resourceDockerImageCustomizeDiff,
+		Importer: &schema.ResourceImporter{
+			State: resourceDockerImageImportState,
+		},
 
 		Schema: map[string]*schema.Schema{
 			"name": {
@@ -22,6 +26,113 @@ func resourceDockerImage() *schema.Resource {
 				Computed: true,
 			},
 
+			"repo_digests": {
+				Type:        schema.TypeList,
+				Description: "Digests of the image in the registries it was pulled from or pushed to",
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+
+			"repo_tags": {
+				Type:        schema.TypeList,
+				Description: "Tags of the image",
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+
+			"size": {
+				Type:        schema.TypeInt,
+				Description: "Size of the image in bytes",
+				Computed:    true,
+			},
+
+			"created": {
+				Type:        schema.TypeString,
+				Description: "Creation date of the image",
+				Computed:    true,
+			},
+
+			"architecture": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"os": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+
+			"labels": {
+				Type:     schema.TypeMap,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+
+			"env": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+
+			"entrypoint": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+
+			"cmd": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+
+			"exposed_ports": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"port": {
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
+						"protocol": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+					},
+				},
+			},
+
+			"healthcheck": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"test": {
+							Type:     schema.TypeList,
+							Computed: true,
+							Elem:     &schema.Schema{Type: schema.TypeString},
+						},
+						"interval": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"timeout": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"start_period": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"retries": {
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
+					},
+				},
+			},
+
 			"keep_locally": {
 				Type:     schema.TypeBool,
 				Optional: true,
@@ -42,6 +153,162 @@ func resourceDockerImage() *schema.Resource {
 				Elem:     &schema.Schema{Type: schema.TypeString},
 				Set:      schema.HashString,
 			},
+
+			"pull_policy": {
+				Type:         schema.TypeString,
+				Description:  "When to pull the image: missing, always or if_newer",
+				Optional:     true,
+				Default:      "missing",
+				ValidateFunc: validateStringMatchesPattern(`^(missing|always|if_newer)$`),
+			},
+
+			"signature_public_key": {
+				Type:         schema.TypeString,
+				Description:   "PEM-encoded public key the pulled image has to be signed with in the registry",
+				Optional:      true,
+				ValidateFunc:  validateStringIsSignaturePublicKey(),
+				ConflictsWith: []string{"build", "source_archive"},
+			},
+
+			"registry_auth": {
+				Type:        schema.TypeList,
+				Description: "Credentials for the registry of the image, overriding the registry_auth of the provider",
+				Optional:    true,
+				MaxItems:    1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"address": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "Address of the registry",
+						},
+
+						"username": {
+							Type:          schema.TypeString,
+							Optional:      true,
+							ConflictsWith: []string{"registry_auth.0.config_file"},
+							Description:   "Username for the registry",
+						},
+
+						"password": {
+							Type:          schema.TypeString,
+							Optional:      true,
+							Sensitive:     true,
+							ConflictsWith: []string{"registry_auth.0.config_file"},
+							Description:   "Password for the registry",
+						},
+
+						"config_file": {
+							Type:          schema.TypeString,
+							Optional:      true,
+							ConflictsWith: []string{"registry_auth.0.username", "registry_auth.0.password"},
+							Description:   "Path to docker json file for registry auth",
+						},
+
+						"ca_material": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "PEM-encoded CA certificates to verify the registry with",
+						},
+
+						"insecure_skip_verify": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Description: "Skip the verification of the TLS certificate of the registry",
+						},
+
+						"scheme": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      "https",
+							Description:  "Scheme of the registry API, http for registries without TLS",
+							ValidateFunc: validateStringMatchesPattern(`^(https|http)$`),
+						},
+					},
+				},
+			},
+
+			"platform": {
+				Type:         schema.TypeString,
+				Description:  "Platform to pull or build the image for, e.g. linux/arm64",
+				Optional:     true,
+				ForceNew:     true,
+				ValidateFunc: validateStringMatchesPattern(platformPattern),
+			},
+
+			"build": {
+				Type:          schema.TypeList,
+				Optional:      true,
+				ForceNew:      true,
+				MaxItems:      1,
+				ConflictsWith: []string{"source_archive"},
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"path": {
+							Type:        schema.TypeString,
+							Description: "Path to the build context directory",
+							Required:    true,
+							ForceNew:    true,
+						},
+						"dockerfile": {
+							Type:        schema.TypeString,
+							Description: "Path to the Dockerfile, relative to the build context",
+							Optional:    true,
+							Default:     "Dockerfile",
+							ForceNew:    true,
+						},
+						"build_args": {
+							Type:        schema.TypeMap,
+							Description: "Build-time variables",
+							Optional:    true,
+							ForceNew:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+						},
+						"target": {
+							Type:        schema.TypeString,
+							Description: "Name of the build stage to build",
+							Optional:    true,
+							ForceNew:    true,
+						},
+						"labels": {
+							Type:        schema.TypeMap,
+							Description: "Labels to set on the image",
+							Optional:    true,
+							ForceNew:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+						},
+						"cache_from": {
+							Type:        schema.TypeList,
+							Description: "Images to consider as cache sources",
+							Optional:    true,
+							ForceNew:    true,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+						},
+					},
+				},
+			},
+
+			"build_context_hash": {
+				Type:        schema.TypeString,
+				Description: "Hash of the build context, changes trigger a rebuild",
+				Computed:    true,
+				ForceNew:    true,
+			},
+
+			"source_archive": {
+				Type:          schema.TypeString,
+				Description:   "Path to a tar archive, as written by docker save, to load the image from",
+				Optional:      true,
+				ForceNew:      true,
+				ConflictsWith: []string{"build"},
+			},
+
+			"source_archive_hash": {
+				Type:        schema.TypeString,
+				Description: "Hash of the source archive, changes trigger a reload",
+				Computed:    true,
+				ForceNew:    true,
+			},
 		},
 	}
 }
@@ -0,0 +1,131 @@
+package docker
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceDockerImageArchive() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceDockerImageArchiveCreate,
+		Read:   resourceDockerImageArchiveRead,
+		Delete: resourceDockerImageArchiveDelete,
+
+		Schema: map[string]*schema.Schema{
+			"image": {
+				Type:        schema.TypeString,
+				Description: "Name or ID of the local image to save",
+				Required:    true,
+				ForceNew:    true,
+			},
+			"path": {
+				Type:        schema.TypeString,
+				Description: "Path of the tar archive to write",
+				Required:    true,
+				ForceNew:    true,
+			},
+			"image_id": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"archive_hash": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+func resourceDockerImageArchiveCreate(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*ProviderConfig).DockerClient
+	ctx := context.Background()
+	imageName := d.Get("image").(string)
+	path := d.Get("path").(string)
+
+	image, _, err := client.ImageInspectWithRaw(ctx, imageName)
+	if err != nil {
+		return fmt.Errorf("Unable to inspect image %s: %s", imageName, err)
+	}
+
+	out, err := client.ImageSave(ctx, []string{imageName})
+	if err != nil {
+		return fmt.Errorf("Unable to save image %s: %s", imageName, err)
+	}
+	defer out.Close()
+
+	// Write to a temporary file first, so that a failed save does not
+	// leave a truncated archive behind
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("Unable to create image archive %s: %s", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), out); err != nil {
+		tmp.Close()
+		return fmt.Errorf("Unable to write image archive %s: %s", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("Unable to write image archive %s: %s", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("Unable to write image archive %s: %s", path, err)
+	}
+
+	d.SetId(image.ID + path)
+	d.Set("image_id", image.ID)
+	d.Set("archive_hash", hex.EncodeToString(hash.Sum(nil)))
+
+	return resourceDockerImageArchiveRead(d, meta)
+}
+
+func resourceDockerImageArchiveRead(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*ProviderConfig).DockerClient
+	imageName := d.Get("image").(string)
+	path := d.Get("path").(string)
+
+	archiveHash, err := hashDockerImageArchive(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Printf("[WARN] Image archive %s no longer exists, removing from state", path)
+			d.SetId("")
+			return nil
+		}
+		return fmt.Errorf("Unable to read image archive %s: %s", path, err)
+	}
+	if archiveHash != d.Get("archive_hash").(string) {
+		log.Printf("[WARN] Image archive %s was modified, removing from state", path)
+		d.SetId("")
+		return nil
+	}
+
+	// A changed image is saved again; an image which is gone locally does
+	// not invalidate the archive written before
+	if image, _, err := client.ImageInspectWithRaw(context.Background(), imageName); err == nil && image.ID != d.Get("image_id").(string) {
+		log.Printf("[WARN] Image %s changed since it was saved to %s, removing from state", imageName, path)
+		d.SetId("")
+		return nil
+	}
+
+	return nil
+}
+
+func resourceDockerImageArchiveDelete(d *schema.ResourceData, meta interface{}) error {
+	path := d.Get("path").(string)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Unable to remove image archive %s: %s", path, err)
+	}
+
+	d.SetId("")
+	return nil
+}
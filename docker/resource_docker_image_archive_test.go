@@ -0,0 +1,69 @@
+package docker
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestAccDockerImageArchive_saveAndLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tf-docker-archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	archive := filepath.Join(dir, "alpine.tar")
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		CheckDestroy: func(s *terraform.State) error {
+			if _, err := os.Stat(archive); !os.IsNotExist(err) {
+				return fmt.Errorf("Image archive %s still exists", archive)
+			}
+			return nil
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(testAccDockerImageArchiveConfig, archive),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttrPair("docker_image_archive.foo", "image_id", "docker_image.foo", "latest"),
+					resource.TestMatchResourceAttr("docker_image_archive.foo", "archive_hash", regexp.MustCompile(`\A[a-f0-9]{64}\z`)),
+				),
+			},
+			{
+				Config: fmt.Sprintf(testAccDockerImageArchiveConfig, archive) + fmt.Sprintf(testAccDockerImageFromArchiveConfig, archive),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttrPair("docker_image.loaded", "latest", "docker_image.foo", "latest"),
+					resource.TestCheckResourceAttrPair("docker_image.loaded", "source_archive_hash", "docker_image_archive.foo", "archive_hash"),
+				),
+			},
+		},
+	})
+}
+
+const testAccDockerImageArchiveConfig = `
+resource "docker_image" "foo" {
+	name = "alpine:3.1"
+	keep_locally = true
+}
+
+resource "docker_image_archive" "foo" {
+	image = "${docker_image.foo.name}"
+	path = "%s"
+}
+`
+
+const testAccDockerImageFromArchiveConfig = `
+resource "docker_image" "loaded" {
+	name = "alpine:3.1"
+	keep_locally = true
+	source_archive = "%s"
+}
+`
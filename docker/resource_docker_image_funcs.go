@@ -1,28 +1,75 @@
 package docker
 
 import (
+	"archive/tar"
 	"context"
 	"fmt"
+	"io"
 	"log"
+	"os"
+	"path/filepath"
 	"strings"
 
-	"bytes"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/builder/dockerignore"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/fileutils"
+	"github.com/docker/docker/pkg/jsonmessage"
 	"github.com/hashicorp/terraform/helper/schema"
 )
 
 func resourceDockerImageCreate(d *schema.ResourceData, meta interface{}) error {
 	client := meta.(*ProviderConfig).DockerClient
 	imageName := d.Get("name").(string)
-	apiImage, err := findImage(imageName, client, meta.(*ProviderConfig).AuthConfigs)
+	providerConfig, err := imageProviderConfig(d.Get("registry_auth").([]interface{}), meta.(*ProviderConfig))
+	if err != nil {
+		return err
+	}
+	if v, ok := d.GetOk("build"); ok {
+		for _, rawBuild := range v.([]interface{}) {
+			build := rawBuild.(map[string]interface{})
+			contextHash, err := hashDockerImageBuildContext(build["path"].(string), build["dockerfile"].(string))
+			if err != nil {
+				return fmt.Errorf("Error hashing build context of image %s: %s", imageName, err)
+			}
+			if err := buildDockerImage(build, imageName, d.Get("platform").(string), client, providerConfig.AuthConfigs); err != nil {
+				return err
+			}
+			d.Set("build_context_hash", contextHash)
+		}
+	}
+	if archive, ok := d.GetOk("source_archive"); ok {
+		archiveHash, err := hashDockerImageArchive(archive.(string))
+		if err != nil {
+			return fmt.Errorf("Error hashing source archive of image %s: %s", imageName, err)
+		}
+		if err := loadImageArchive(client, archive.(string), imageName); err != nil {
+			return err
+		}
+		d.Set("source_archive_hash", archiveHash)
+	}
+	if err := pullImageForResource(d, providerConfig); err != nil {
+		return err
+	}
+
+	apiImage, err := findImage(imageName, d.Get("platform").(string), providerConfig)
 	if err != nil {
 		return fmt.Errorf("Unable to read Docker image into resource: %s", err)
 	}
 
+	if publicKey := d.Get("signature_public_key").(string); publicKey != "" {
+		if err := verifyLocalImageSignature(apiImage, imageName, publicKey, providerConfig); err != nil {
+			return fmt.Errorf("Signature verification of image %s failed: %s", imageName, err)
+		}
+	}
+
 	d.SetId(apiImage.ID + d.Get("name").(string))
 	d.Set("latest", apiImage.ID)
 
@@ -31,31 +78,79 @@ func resourceDockerImageCreate(d *schema.ResourceData, meta interface{}) error {
 
 func resourceDockerImageRead(d *schema.ResourceData, meta interface{}) error {
 	client := meta.(*ProviderConfig).DockerClient
-	var data Data
-	if err := fetchLocalImages(&data, client); err != nil {
-		return fmt.Errorf("Error reading docker image list: %s", err)
+
+	// The image is looked up by its ID, as the name may have been moved to
+	// another image meanwhile, e.g. by a pull outside of Terraform
+	imageID := d.Get("latest").(string)
+	if imageID == "" {
+		imageID = d.Get("name").(string)
+	}
+	foundImage, err := searchLocalImage(client, imageID)
+	if err != nil {
+		return fmt.Errorf("Error reading docker image: %s", err)
 	}
-	foundImage := searchLocalImages(data, d.Get("name").(string))
 
-	if foundImage != nil {
-		d.Set("latest", foundImage.ID)
-	} else {
+	if foundImage == nil {
+		log.Printf("[WARN] Image %s (%s) not found, removing from state", d.Get("name").(string), imageID)
 		d.SetId("")
+		return nil
 	}
 
+	d.Set("latest", foundImage.ID)
+	setImageMetadata(d, foundImage)
+
 	return nil
 }
 
+// setImageMetadata sets the computed attributes describing the inspected
+// image, which the image resource and data source have in common.
+func setImageMetadata(d *schema.ResourceData, foundImage *types.ImageInspect) {
+	d.Set("repo_digests", foundImage.RepoDigests)
+	d.Set("repo_tags", foundImage.RepoTags)
+	d.Set("size", int(foundImage.Size))
+	d.Set("created", foundImage.Created)
+	d.Set("architecture", foundImage.Architecture)
+	d.Set("os", foundImage.Os)
+
+	imageConfig := foundImage.Config
+	if imageConfig == nil {
+		imageConfig = &container.Config{}
+	}
+	d.Set("labels", mapStringStringToMapStringInterface(imageConfig.Labels))
+	d.Set("env", imageConfig.Env)
+	d.Set("entrypoint", []string(imageConfig.Entrypoint))
+	d.Set("cmd", []string(imageConfig.Cmd))
+	if err := d.Set("exposed_ports", flattenImageExposedPorts(imageConfig.ExposedPorts)); err != nil {
+		log.Printf("[WARN] failed to set exposed ports from API: %s", err)
+	}
+	if err := d.Set("healthcheck", flattenServiceHealthcheck(imageConfig.Healthcheck)); err != nil {
+		log.Printf("[WARN] failed to set healthcheck from API: %s", err)
+	}
+}
+
 func resourceDockerImageUpdate(d *schema.ResourceData, meta interface{}) error {
 	// We need to re-read in case switching parameters affects
 	// the value of "latest" or others
-	client := meta.(*ProviderConfig).DockerClient
 	imageName := d.Get("name").(string)
-	apiImage, err := findImage(imageName, client, meta.(*ProviderConfig).AuthConfigs)
+	providerConfig, err := imageProviderConfig(d.Get("registry_auth").([]interface{}), meta.(*ProviderConfig))
+	if err != nil {
+		return err
+	}
+	if err := pullImageForResource(d, providerConfig); err != nil {
+		return err
+	}
+
+	apiImage, err := findImage(imageName, d.Get("platform").(string), providerConfig)
 	if err != nil {
 		return fmt.Errorf("Unable to read Docker image into resource: %s", err)
 	}
 
+	if publicKey := d.Get("signature_public_key").(string); publicKey != "" {
+		if err := verifyLocalImageSignature(apiImage, imageName, publicKey, providerConfig); err != nil {
+			return fmt.Errorf("Signature verification of image %s failed: %s", imageName, err)
+		}
+	}
+
 	d.Set("latest", apiImage.ID)
 
 	return resourceDockerImageRead(d, meta)
@@ -71,34 +166,299 @@ func resourceDockerImageDelete(d *schema.ResourceData, meta interface{}) error {
 	return nil
 }
 
-func searchLocalImages(data Data, imageName string) *types.ImageSummary {
-	if apiImage, ok := data.DockerImages[imageName]; ok {
-		return apiImage
+// resourceDockerImageCustomizeDiff plans a new image whenever the content of
+// its build context or of its source archive changes, and plans a pull when
+// the pull policy asks for one. The signature of an image which is going to
+// be pulled is verified, so that the plan fails for unsigned images.
+func resourceDockerImageCustomizeDiff(d *schema.ResourceDiff, meta interface{}) error {
+	// A build context or source archive which is not known or doesn't exist
+	// yet, e.g. because it is interpolated or created by another resource,
+	// is hashed again when the plan is applied
+	if builds := d.Get("build").([]interface{}); len(builds) > 0 && builds[0] != nil {
+		build := builds[0].(map[string]interface{})
+		contextDir := build["path"].(string)
+		if !d.NewValueKnown("build") || contextDir == "" {
+			if err := d.SetNewComputed("build_context_hash"); err != nil {
+				return err
+			}
+		} else {
+			contextHash, err := hashDockerImageBuildContext(contextDir, build["dockerfile"].(string))
+			switch {
+			case os.IsNotExist(err):
+				log.Printf("[DEBUG] Build context %s doesn't exist yet: %s", contextDir, err)
+				if err := d.SetNewComputed("build_context_hash"); err != nil {
+					return err
+				}
+			case err != nil:
+				return fmt.Errorf("Error hashing build context %s: %s", contextDir, err)
+			case d.Get("build_context_hash").(string) != contextHash:
+				if err := d.SetNew("build_context_hash", contextHash); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if archive := d.Get("source_archive").(string); !d.NewValueKnown("source_archive") {
+		if err := d.SetNewComputed("source_archive_hash"); err != nil {
+			return err
+		}
+	} else if archive != "" {
+		archiveHash, err := hashDockerImageArchive(archive)
+		switch {
+		case os.IsNotExist(err):
+			log.Printf("[DEBUG] Source archive %s doesn't exist yet: %s", archive, err)
+			if err := d.SetNewComputed("source_archive_hash"); err != nil {
+				return err
+			}
+		case err != nil:
+			return fmt.Errorf("Error hashing source archive %s: %s", archive, err)
+		case d.Get("source_archive_hash").(string) != archiveHash:
+			if err := d.SetNew("source_archive_hash", archiveHash); err != nil {
+				return err
+			}
+		}
 	}
-	if apiImage, ok := data.DockerImages[imageName+":latest"]; ok {
-		imageName = imageName + ":latest"
-		return apiImage
+
+	if len(d.Get("build").([]interface{})) > 0 || d.Get("source_archive").(string) != "" || !d.NewValueKnown("source_archive") {
+		// an image which is built or loaded locally can't be pulled with
+		// another name, so it is built or loaded again when renamed
+		if d.Id() != "" && d.HasChange("name") {
+			return d.ForceNew("name")
+		}
+		return nil
 	}
+
+	imageName := d.Get("name").(string)
+	pullPlanned := d.Id() == "" || d.HasChange("name") || d.HasChange("signature_public_key")
+	if d.Id() != "" {
+		switch d.Get("pull_policy").(string) {
+		case "always", "if_newer":
+			// A pull is only planned for a newer image, so that the plan is
+			// empty as long as the registry has the local image. Digest
+			// references can't change.
+			if strings.Contains(imageName, "@") {
+				break
+			}
+			providerConfig, err := imageProviderConfig(d.Get("registry_auth").([]interface{}), meta.(*ProviderConfig))
+			if err != nil {
+				return err
+			}
+			repoDigests := stringListToStringSlice(d.Get("repo_digests").([]interface{}))
+			outdated, err := imageIsOutdated(repoDigests, imageName, providerConfig)
+			if err != nil {
+				return err
+			}
+			if outdated {
+				log.Printf("[INFO] A newer version of image %s is available in the registry", imageName)
+				if err := d.SetNewComputed("latest"); err != nil {
+					return err
+				}
+				pullPlanned = true
+			}
+		}
+	}
+
+	// the name or the key are empty as long as they are not known
+	if publicKey := d.Get("signature_public_key").(string); publicKey != "" && imageName != "" && pullPlanned {
+		providerConfig, err := imageProviderConfig(d.Get("registry_auth").([]interface{}), meta.(*ProviderConfig))
+		if err != nil {
+			return err
+		}
+		if _, err := verifyImageSignature(imageName, publicKey, providerConfig); err != nil {
+			return fmt.Errorf("Signature verification of image %s failed: %s", imageName, err)
+		}
+	}
+
 	return nil
 }
 
-func removeImage(d *schema.ResourceData, client *client.Client) error {
-	var data Data
+// imageProviderConfig returns the provider configuration with the
+// credentials of the registry_auth block of the image, if any, taking
+// precedence over the credentials of the provider for that registry.
+func imageProviderConfig(rawRegistryAuth []interface{}, providerConfig *ProviderConfig) (*ProviderConfig, error) {
+	if len(rawRegistryAuth) == 0 || rawRegistryAuth[0] == nil {
+		return providerConfig, nil
+	}
 
-	if keepLocally := d.Get("keep_locally").(bool); keepLocally {
+	authConfig, err := registryAuthToAuthConfig(rawRegistryAuth[0].(map[string]interface{}))
+	if err != nil {
+		return nil, fmt.Errorf("Error loading registry auth config of image: %s", err)
+	}
+
+	authConfigs := &AuthConfigs{
+		Configs:    map[string]types.AuthConfig{},
+		Registries: map[string]registryOptions{},
+	}
+	if providerConfig.AuthConfigs != nil {
+		for address, config := range providerConfig.AuthConfigs.Configs {
+			authConfigs.Configs[address] = config
+		}
+		for address, options := range providerConfig.AuthConfigs.Registries {
+			authConfigs.Registries[address] = options
+		}
+	}
+	authConfigs.Configs[authConfig.ServerAddress] = authConfig
+	authConfigs.Registries[authConfig.ServerAddress] = registryAuthToRegistryOptions(rawRegistryAuth[0].(map[string]interface{}))
+
+	return &ProviderConfig{
+		DockerClient:   providerConfig.DockerClient,
+		AuthConfigs:    authConfigs,
+		ImagePuller:    providerConfig.ImagePuller,
+		RegistryTokens: providerConfig.RegistryTokens,
+	}, nil
+}
+
+// imageIsBuiltLocally reports whether the image is built or loaded from an
+// archive instead of being pulled from a registry.
+func imageIsBuiltLocally(d *schema.ResourceData) bool {
+	return len(d.Get("build").([]interface{})) > 0 || d.Get("source_archive").(string) != ""
+}
+
+// pullImageForResource pulls the image of the resource unless it is built
+// locally. Images with a signature_public_key are pulled by the digest of
+// their verified manifest, other images as their pull policy asks.
+func pullImageForResource(d *schema.ResourceData, providerConfig *ProviderConfig) error {
+	if imageIsBuiltLocally(d) {
 		return nil
 	}
 
-	if err := fetchLocalImages(&data, client); err != nil {
+	imageName := d.Get("name").(string)
+	if publicKey := d.Get("signature_public_key").(string); publicKey != "" {
+		return pullVerifiedImage(providerConfig, imageName, d.Get("platform").(string), publicKey)
+	}
+	return pullImageByPolicy(providerConfig, imageName, d.Get("platform").(string), d.Get("pull_policy").(string))
+}
+
+// pullVerifiedImage verifies the signature of the image in its registry and
+// pulls the verified manifest by its digest, which is then tagged with the
+// name of the image. The pulled image is the verified one even if the tag is
+// moved in the meantime.
+func pullVerifiedImage(providerConfig *ProviderConfig, imageName, platform, publicKey string) error {
+	digest, err := verifyImageSignature(imageName, publicKey, providerConfig)
+	if err != nil {
+		return fmt.Errorf("Signature verification of image %s failed: %s", imageName, err)
+	}
+
+	reference := parseImageOptions(imageName).Repository + "@" + digest
+	if err := pullImage(providerConfig, reference, platform); err != nil {
+		return fmt.Errorf("Unable to pull image %s: %s", reference, err)
+	}
+
+	// digest references can't be tagged, they refer to the pulled image
+	if strings.Contains(imageName, "@") {
+		return nil
+	}
+	if err := providerConfig.DockerClient.ImageTag(context.Background(), reference, imageName); err != nil {
+		return fmt.Errorf("Error tagging image %s as %s: %s", reference, imageName, err)
+	}
+	return nil
+}
+
+// pullImageByPolicy pulls a local image again if the pull policy asks for it.
+// Images missing locally are pulled by findImage regardless of the policy.
+func pullImageByPolicy(providerConfig *ProviderConfig, imageName, platform, pullPolicy string) error {
+	if pullPolicy == "missing" || pullPolicy == "" {
+		return nil
+	}
+
+	foundImage, err := searchLocalImage(providerConfig.DockerClient, imageName)
+	if err != nil || foundImage == nil {
 		return err
 	}
 
+	if pullPolicy == "if_newer" {
+		if strings.Contains(imageName, "@") {
+			return nil
+		}
+		outdated, err := imageIsOutdated(foundImage.RepoDigests, imageName, providerConfig)
+		if err != nil || !outdated {
+			return err
+		}
+	}
+
+	if err := pullImage(providerConfig, imageName, platform); err != nil {
+		return fmt.Errorf("Unable to pull image %s: %s", imageName, err)
+	}
+	return nil
+}
+
+// imageIsOutdated reports whether the digest of the image in the registry is
+// none of the repo digests of the local image.
+func imageIsOutdated(repoDigests []string, imageName string, providerConfig *ProviderConfig) (bool, error) {
+	// Without a platform the digest of a multi-platform image is the digest of
+	// the manifest list, which is what the daemon records after a pull
+	digest, _, err := getRegistryImageDigest(imageName, "", providerConfig)
+	if err != nil {
+		return false, fmt.Errorf("Unable to get the digest of image %s from the registry: %s", imageName, err)
+	}
+
+	for _, repoDigest := range repoDigests {
+		if strings.HasSuffix(repoDigest, "@"+digest) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// resourceDockerImageImportState looks up the image by its name or ID. Images
+// imported by ID are named after their first tag, if they have one.
+func resourceDockerImageImportState(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	client := meta.(*ProviderConfig).DockerClient
+
+	image, _, err := client.ImageInspectWithRaw(context.Background(), d.Id())
+	if err != nil {
+		return nil, fmt.Errorf("Error inspecting image %s: %s", d.Id(), err)
+	}
+
+	imageName := d.Id()
+	if strings.HasPrefix(image.ID, imageName) || strings.HasPrefix(strings.TrimPrefix(image.ID, "sha256:"), imageName) {
+		imageName = image.ID
+		if len(image.RepoTags) > 0 {
+			imageName = image.RepoTags[0]
+		}
+	}
+
+	d.SetId(image.ID + imageName)
+	d.Set("name", imageName)
+	d.Set("latest", image.ID)
+
+	return []*schema.ResourceData{d}, nil
+}
+
+// searchLocalImage inspects the local image with the given name, ID or digest
+// reference. It returns nil if there is no such image.
+func searchLocalImage(client *client.Client, imageName string) (*types.ImageInspect, error) {
+	image, _, err := client.ImageInspectWithRaw(context.Background(), imageName)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("Unable to inspect image %s: %s", imageName, err)
+	}
+	return &image, nil
+}
+
+func removeImage(d *schema.ResourceData, client *client.Client) error {
+	if keepLocally := d.Get("keep_locally").(bool); keepLocally {
+		return nil
+	}
+
 	imageName := d.Get("name").(string)
 	if imageName == "" {
 		return fmt.Errorf("Empty image name is not allowed")
 	}
 
-	foundImage := searchLocalImages(data, imageName)
+	// The name might have been removed or moved to another image meanwhile,
+	// so the image the resource created is looked up by its ID
+	imageID := d.Get("latest").(string)
+	if imageID == "" {
+		imageID = imageName
+	}
+	foundImage, err := searchLocalImage(client, imageID)
+	if err != nil {
+		return err
+	}
 
 	if foundImage != nil {
 		imageDeleteResponseItems, err := client.ImageRemove(context.Background(), foundImage.ID, types.ImageRemoveOptions{})
@@ -111,34 +471,119 @@ func removeImage(d *schema.ResourceData, client *client.Client) error {
 	return nil
 }
 
-func fetchLocalImages(data *Data, client *client.Client) error {
-	images, err := client.ImageList(context.Background(), types.ImageListOptions{All: false})
+// pullImage pulls the image through the image puller of the provider, which
+// joins a pull of the same image already in progress.
+func pullImage(providerConfig *ProviderConfig, image, platform string) error {
+	registry := parseImageOptions(image).Registry
+	if registry == "" {
+		registry = "registry.hub.docker.com"
+	}
+
+	return providerConfig.ImagePuller.pull(imagePullKey(image, platform, providerConfig.AuthConfigs), registry, func() error {
+		return pullImageFromRegistry(providerConfig.DockerClient, providerConfig.AuthConfigs, image, platform)
+	})
+}
+
+// imagePullKey identifies the pull of the image for the platform with the
+// credentials it is pulled with, so that only pulls with the same credentials
+// share their result.
+func imagePullKey(image, platform string, authConfigs *AuthConfigs) string {
+	auth := getAuthConfigForImage(image, authConfigs)
+	credentials, _ := json.Marshal(auth)
+	sum := sha256.Sum256(credentials)
+	return image + "|" + platform + "|" + auth.ServerAddress + "|" + hex.EncodeToString(sum[:])
+}
+
+func pullImageFromRegistry(client *client.Client, authConfig *AuthConfigs, image, platform string) error {
+	encodedJSON, err := json.Marshal(getAuthConfigForImage(image, authConfig))
 	if err != nil {
-		return fmt.Errorf("Unable to list Docker images: %s", err)
+		return fmt.Errorf("error creating auth config: %s", err)
 	}
 
-	if data.DockerImages == nil {
-		data.DockerImages = make(map[string]*types.ImageSummary)
+	out, err := client.ImagePull(context.Background(), image, types.ImagePullOptions{
+		RegistryAuth: base64.URLEncoding.EncodeToString(encodedJSON),
+		Platform:     platform,
+	})
+	if err != nil {
+		return fmt.Errorf("error pulling image %s: %s", image, err)
 	}
+	defer out.Close()
+
+	return readImagePullOutput(out, image)
+}
 
-	// Docker uses different nomenclatures in different places...sometimes a short
-	// ID, sometimes long, etc. So we store both in the map so we can always find
-	// the same image object. We store the tags and digests, too.
-	for i, image := range images {
-		data.DockerImages[image.ID[:12]] = &images[i]
-		data.DockerImages[image.ID] = &images[i]
-		for _, repotag := range image.RepoTags {
-			data.DockerImages[repotag] = &images[i]
+// imagePullError is an error reported by the daemon in the output of a pull,
+// such as an unknown manifest or a denied access.
+type imagePullError struct {
+	Registry   string
+	Repository string
+	Message    string
+}
+
+func (e *imagePullError) Error() string {
+	return fmt.Sprintf("Error pulling %s from registry %s: %s", e.Repository, e.Registry, e.Message)
+}
+
+// readImagePullOutput decodes the JSON messages of a pull, logs the progress
+// of each layer and returns the first error of the stream as an
+// *imagePullError.
+func readImagePullOutput(out io.Reader, image string) error {
+	// progress of a layer is logged in steps of a quarter of its size
+	lastStatus := make(map[string]string)
+	lastQuarter := make(map[string]int64)
+
+	decoder := json.NewDecoder(out)
+	for {
+		var message jsonmessage.JSONMessage
+		if err := decoder.Decode(&message); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return fmt.Errorf("Error reading pull output of image %s: %s", image, err)
 		}
-		for _, repodigest := range image.RepoDigests {
-			data.DockerImages[repodigest] = &images[i]
+
+		if message.Error != nil || message.ErrorMessage != "" {
+			errorMessage := message.ErrorMessage
+			if message.Error != nil {
+				errorMessage = message.Error.Message
+			}
+			pullOpts := parseImageOptions(image)
+			registry := pullOpts.Registry
+			if registry == "" {
+				registry = "registry.hub.docker.com"
+			}
+			return &imagePullError{
+				Registry:   registry,
+				Repository: pullOpts.Repository,
+				Message:    errorMessage,
+			}
+		}
+
+		if message.ID == "" {
+			if message.Status != "" {
+				log.Printf("[DEBUG] Pulling image %s: %s", image, message.Status)
+			}
+			continue
 		}
+
+		if message.Progress != nil && message.Progress.Total > 0 {
+			quarter := message.Progress.Current * 4 / message.Progress.Total
+			if message.Status == lastStatus[message.ID] && quarter == lastQuarter[message.ID] {
+				continue
+			}
+			lastQuarter[message.ID] = quarter
+			log.Printf("[DEBUG] Pulling image %s: layer %s: %s %d/%d bytes", image, message.ID, message.Status, message.Progress.Current, message.Progress.Total)
+		} else if message.Status != lastStatus[message.ID] {
+			log.Printf("[DEBUG] Pulling image %s: layer %s: %s", image, message.ID, message.Status)
+		}
+		lastStatus[message.ID] = message.Status
 	}
 
 	return nil
 }
 
-func pullImage(data *Data, client *client.Client, authConfig *AuthConfigs, image string) error {
+// getAuthConfigForImage returns the credentials for the registry of the image
+func getAuthConfigForImage(image string, authConfig *AuthConfigs) types.AuthConfig {
 	pullOpts := parseImageOptions(image)
 
 	// If a registry was specified in the image name, try to find auth for it
@@ -153,28 +598,270 @@ func pullImage(data *Data, client *client.Client, authConfig *AuthConfigs, image
 			auth = authConfig
 		}
 	}
+	return auth
+}
+
+func buildDockerImage(rawBuild map[string]interface{}, imageName, platform string, client *client.Client, authConfig *AuthConfigs) error {
+	contextDir := rawBuild["path"].(string)
+	dockerfile := rawBuild["dockerfile"].(string)
 
-	encodedJSON, err := json.Marshal(auth)
+	buildContext, err := buildDockerImageContextTar(contextDir, dockerfile)
 	if err != nil {
-		return fmt.Errorf("error creating auth config: %s", err)
+		return fmt.Errorf("Error creating build context for image %s: %s", imageName, err)
 	}
+	defer buildContext.Close()
 
-	out, err := client.ImagePull(context.Background(), image, types.ImagePullOptions{
-		RegistryAuth: base64.URLEncoding.EncodeToString(encodedJSON),
+	buildArgs := make(map[string]*string)
+	for k, v := range rawBuild["build_args"].(map[string]interface{}) {
+		value := v.(string)
+		buildArgs[k] = &value
+	}
+
+	buildOptions := types.ImageBuildOptions{
+		Tags:        []string{imageName},
+		Dockerfile:  filepath.ToSlash(dockerfile),
+		BuildArgs:   buildArgs,
+		Target:      rawBuild["target"].(string),
+		Labels:      mapTypeMapValsToString(rawBuild["labels"].(map[string]interface{})),
+		CacheFrom:   stringListToStringSlice(rawBuild["cache_from"].([]interface{})),
+		AuthConfigs: authConfig.Configs,
+		Platform:    platform,
+		Remove:      true,
+	}
+
+	response, err := client.ImageBuild(context.Background(), buildContext, buildOptions)
+	if err != nil {
+		return fmt.Errorf("Error building image %s: %s", imageName, err)
+	}
+	defer response.Body.Close()
+
+	decoder := json.NewDecoder(response.Body)
+	for {
+		var message jsonmessage.JSONMessage
+		if err := decoder.Decode(&message); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return fmt.Errorf("Error reading build output of image %s: %s", imageName, err)
+		}
+		if message.Error != nil {
+			return fmt.Errorf("Error building image %s: %s", imageName, message.Error.Message)
+		}
+		if stream := strings.TrimSpace(message.Stream); stream != "" {
+			log.Printf("[DEBUG] Building image %s: %s", imageName, stream)
+		} else if message.Status != "" {
+			log.Printf("[DEBUG] Building image %s: %s %s", imageName, message.ID, message.Status)
+		}
+	}
+
+	return nil
+}
+
+// buildDockerImageContextTar packs the build context into a tar archive,
+// leaving out the files excluded by the .dockerignore file of the context.
+func buildDockerImageContextTar(contextDir, dockerfile string) (io.ReadCloser, error) {
+	if _, err := os.Stat(contextDir); err != nil {
+		return nil, err
+	}
+
+	// The archive is streamed to the daemon instead of being held in memory,
+	// errors while packing it fail the read of the build context
+	pr, pw := io.Pipe()
+	go func() {
+		tw := tar.NewWriter(pw)
+		err := walkDockerImageBuildContext(contextDir, dockerfile, func(path, relPath string, info os.FileInfo) error {
+			link := ""
+			if info.Mode()&os.ModeSymlink != 0 {
+				var err error
+				if link, err = os.Readlink(path); err != nil {
+					return err
+				}
+			}
+
+			header, err := tar.FileInfoHeader(info, link)
+			if err != nil {
+				return err
+			}
+			header.Name = filepath.ToSlash(relPath)
+			if info.IsDir() {
+				header.Name += "/"
+			}
+			if err := tw.WriteHeader(header); err != nil {
+				return err
+			}
+
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			_, err = io.Copy(tw, f)
+			return err
+		})
+		if err == nil {
+			err = tw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr, nil
+}
+
+// walkDockerImageBuildContext calls fn for every file of the build context
+// which is not excluded by the .dockerignore file. The Dockerfile and the
+// .dockerignore file itself are always part of the context, as with docker build.
+func walkDockerImageBuildContext(contextDir, dockerfile string, fn func(path, relPath string, info os.FileInfo) error) error {
+	excludes, err := readDockerIgnore(contextDir)
+	if err != nil {
+		return err
+	}
+	matcher, err := fileutils.NewPatternMatcher(excludes)
+	if err != nil {
+		return fmt.Errorf("Invalid .dockerignore pattern: %s", err)
+	}
+	keep := map[string]bool{
+		filepath.Clean(dockerfile): true,
+		".dockerignore":            true,
+	}
+
+	return filepath.Walk(contextDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(contextDir, path)
+		if err != nil {
+			return err
+		}
+		if relPath == "." {
+			return nil
+		}
+
+		if !keep[relPath] {
+			excluded, err := matcher.Matches(relPath)
+			if err != nil {
+				return err
+			}
+			if excluded {
+				// A directory can only be skipped as a whole when no
+				// exception pattern could include one of its files again
+				if info.IsDir() && !matcher.Exclusions() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+
+		return fn(path, relPath, info)
+	})
+}
+
+// hashDockerImageBuildContext returns a SHA256 hash over the paths, modes and
+// contents of the files which are sent to the Docker daemon on a build.
+func hashDockerImageBuildContext(contextDir, dockerfile string) (string, error) {
+	hash := sha256.New()
+
+	err := walkDockerImageBuildContext(contextDir, dockerfile, func(path, relPath string, info os.FileInfo) error {
+		fmt.Fprintf(hash, "%s\x00%s\x00", filepath.ToSlash(relPath), info.Mode())
+
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			hash.Write([]byte(link))
+		case info.Mode().IsRegular():
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			if _, err := io.Copy(hash, f); err != nil {
+				return err
+			}
+		}
+		hash.Write([]byte{0})
+		return nil
 	})
 	if err != nil {
-		return fmt.Errorf("error pulling image %s: %s", image, err)
+		return "", err
 	}
-	defer out.Close()
 
-	buf := new(bytes.Buffer)
-	buf.ReadFrom(out)
-	s := buf.String()
-	log.Printf("[DEBUG] pulled image %v: %v", image, s)
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
 
+// loadImageArchive loads the images of a tar archive, as written by docker save,
+// and makes sure the archive contained the given image.
+func loadImageArchive(client *client.Client, archive, imageName string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return fmt.Errorf("Error opening source archive of image %s: %s", imageName, err)
+	}
+	defer f.Close()
+
+	response, err := client.ImageLoad(context.Background(), f, true)
+	if err != nil {
+		return fmt.Errorf("Error loading image archive %s: %s", archive, err)
+	}
+	defer response.Body.Close()
+
+	decoder := json.NewDecoder(response.Body)
+	for {
+		var message jsonmessage.JSONMessage
+		if err := decoder.Decode(&message); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return fmt.Errorf("Error reading load output of archive %s: %s", archive, err)
+		}
+		if message.Error != nil {
+			return fmt.Errorf("Error loading image archive %s: %s", archive, message.Error.Message)
+		}
+		if stream := strings.TrimSpace(message.Stream); stream != "" {
+			log.Printf("[DEBUG] Loading image archive %s: %s", archive, stream)
+		}
+	}
+
+	if _, _, err := client.ImageInspectWithRaw(context.Background(), imageName); err != nil {
+		return fmt.Errorf("Image archive %s does not contain the image %s: %s", archive, imageName, err)
+	}
 	return nil
 }
 
+// hashDockerImageArchive returns the SHA256 hash of the content of an image archive
+func hashDockerImageArchive(archive string) (string, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func readDockerIgnore(contextDir string) ([]string, error) {
+	f, err := os.Open(filepath.Join(contextDir, ".dockerignore"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	excludes, err := dockerignore.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading .dockerignore: %s", err)
+	}
+	return excludes, nil
+}
+
 type internalPullImageOptions struct {
 	Repository string `qs:"fromImage"`
 	Tag        string
@@ -200,6 +887,17 @@ func parseImageOptions(image string) internalPullImageOptions {
 		pullOpts.Registry = image[:firstSlash]
 	}
 
+	// A digest reference, name@sha256:..., is used as the tag; a tag given in
+	// front of the digest is ignored like docker pull does
+	if digestIndex := strings.Index(image, "@"); digestIndex != -1 {
+		pullOpts.Repository = image[:digestIndex]
+		pullOpts.Tag = image[digestIndex+1:]
+		if tagIndex := strings.LastIndex(pullOpts.Repository, ":"); tagIndex > strings.LastIndex(pullOpts.Repository, "/") {
+			pullOpts.Repository = pullOpts.Repository[:tagIndex]
+		}
+		return pullOpts
+	}
+
 	prefixLength := len(pullOpts.Registry)
 	tagIndex := strings.Index(image[prefixLength:], ":")
 
@@ -212,35 +910,46 @@ func parseImageOptions(image string) internalPullImageOptions {
 	return pullOpts
 }
 
-func findImage(imageName string, client *client.Client, authConfig *AuthConfigs) (*types.ImageSummary, error) {
+// findImage looks up the image locally and pulls it if it is missing. If a
+// platform is given, a local image of another platform is pulled again.
+func findImage(imageName, platform string, providerConfig *ProviderConfig) (*types.ImageInspect, error) {
 	if imageName == "" {
 		return nil, fmt.Errorf("Empty image name is not allowed")
 	}
 
-	var data Data
-	// load local images into the data structure
-	if err := fetchLocalImages(&data, client); err != nil {
+	client := providerConfig.DockerClient
+	foundImage, err := searchLocalImage(client, imageName)
+	if err != nil {
 		return nil, err
 	}
-
-	foundImage := searchLocalImages(data, imageName)
-	if foundImage != nil {
+	if foundImage != nil && imageMatchesPlatform(foundImage, platform) {
 		return foundImage, nil
 	}
 
-	if err := pullImage(&data, client, authConfig, imageName); err != nil {
+	if err := pullImage(providerConfig, imageName, platform); err != nil {
 		return nil, fmt.Errorf("Unable to pull image %s: %s", imageName, err)
 	}
 
-	// update the data structure of the images
-	if err := fetchLocalImages(&data, client); err != nil {
+	foundImage, err = searchLocalImage(client, imageName)
+	if err != nil {
 		return nil, err
 	}
-
-	foundImage = searchLocalImages(data, imageName)
 	if foundImage != nil {
 		return foundImage, nil
 	}
 
 	return nil, fmt.Errorf("Unable to find or pull image %s", imageName)
 }
+
+// imageMatchesPlatform reports whether the local image was built for the
+// platform. Every image matches if no platform is given.
+func imageMatchesPlatform(image *types.ImageInspect, platform string) bool {
+	if platform == "" {
+		return true
+	}
+
+	// The variant is not part of the local image metadata, so only the
+	// operating system and the architecture are compared
+	parts := strings.SplitN(platform, "/", 3)
+	return platformMatches(parts[0]+"/"+parts[1], formatPlatform(image.Os, image.Architecture, ""))
+}
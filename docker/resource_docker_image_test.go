@@ -1,12 +1,21 @@
 package docker
 
 import (
+	"archive/tar"
 	"context"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"reflect"
 	"regexp"
+	"sort"
+	"strings"
 	"testing"
 
+	"github.com/docker/docker/api/types"
+	"github.com/hashicorp/terraform/configs/hcl2shim"
 	"github.com/hashicorp/terraform/helper/resource"
 	"github.com/hashicorp/terraform/terraform"
 )
@@ -23,8 +32,70 @@ func TestAccDockerImage_basic(t *testing.T) {
 				Config: testAccDockerImageConfig,
 				Check: resource.ComposeTestCheckFunc(
 					resource.TestMatchResourceAttr("docker_image.foo", "latest", contentDigestRegexp),
+					resource.TestCheckResourceAttr("docker_image.foo", "repo_tags.#", "1"),
+					resource.TestCheckResourceAttr("docker_image.foo", "repo_tags.0", "alpine:3.1"),
+					resource.TestMatchResourceAttr("docker_image.foo", "repo_digests.0", regexp.MustCompile(`\Aalpine@sha256:[a-f0-9]{64}\z`)),
+					resource.TestMatchResourceAttr("docker_image.foo", "size", regexp.MustCompile(`\A[1-9][0-9]*\z`)),
+					resource.TestCheckResourceAttrSet("docker_image.foo", "created"),
+					resource.TestCheckResourceAttr("docker_image.foo", "os", "linux"),
+					testAccCheckImageDaemonArchitecture("docker_image.foo"),
+					resource.TestCheckResourceAttr("docker_image.foo", "cmd.#", "1"),
+					resource.TestCheckResourceAttr("docker_image.foo", "cmd.0", "/bin/sh"),
 				),
 			},
+			{
+				ResourceName:            "docker_image.foo",
+				ImportState:             true,
+				ImportStateVerify:       true,
+				ImportStateVerifyIgnore: []string{"keep_locally"},
+			},
+		},
+	})
+}
+
+func TestAccDockerImage_nameMoved(t *testing.T) {
+	var imageID string
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDockerImageNameMovedConfig,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestMatchResourceAttr("docker_image.foo", "latest", contentDigestRegexp),
+					func(s *terraform.State) error {
+						imageID = s.RootModule().Resources["docker_image.foo"].Primary.Attributes["latest"]
+						return nil
+					},
+				),
+			},
+			{
+				// the name is moved to another image outside of Terraform
+				PreConfig: func() {
+					client := testAccProvider.Meta().(*ProviderConfig).DockerClient
+					if err := client.ImageTag(context.Background(), "busybox:1.31", "alpine:3.1"); err != nil {
+						t.Fatalf("Unable to tag image: %s", err)
+					}
+				},
+				Config: testAccDockerImageNameMovedConfig,
+				Check: resource.ComposeTestCheckFunc(
+					func(s *terraform.State) error {
+						return resource.TestCheckResourceAttr("docker_image.foo", "latest", imageID)(s)
+					},
+				),
+			},
+			{
+				// the image is removed outside of Terraform
+				PreConfig: func() {
+					client := testAccProvider.Meta().(*ProviderConfig).DockerClient
+					if _, err := client.ImageRemove(context.Background(), imageID, types.ImageRemoveOptions{Force: true}); err != nil {
+						t.Fatalf("Unable to remove image: %s", err)
+					}
+				},
+				Config:             testAccDockerImageNameMovedConfig,
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
 		},
 	})
 }
@@ -148,6 +219,130 @@ func TestAccDockerImage_data_private_config_file(t *testing.T) {
 	})
 }
 
+func TestAccDockerImage_registryAuth(t *testing.T) {
+	registry := "127.0.0.1:15000"
+	image := "127.0.0.1:15000/tftest-service:v1"
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:                  func() { testAccPreCheck(t) },
+		Providers:                 testAccProviders,
+		PreventPostDestroyRefresh: true,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(testAccDockerImageRegistryAuthConfig, image, registry),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestMatchResourceAttr("docker_image.foo_private", "latest", contentDigestRegexp),
+				),
+			},
+		},
+		CheckDestroy: checkAndRemoveImages,
+	})
+}
+
+func TestImageProviderConfig(t *testing.T) {
+	providerConfig := &ProviderConfig{
+		AuthConfigs: &AuthConfigs{Configs: map[string]types.AuthConfig{
+			"https://127.0.0.1:15000":         {ServerAddress: "https://127.0.0.1:15000", Username: "provider"},
+			"https://registry.hub.docker.com": {ServerAddress: "https://registry.hub.docker.com", Username: "hub"},
+		}},
+	}
+
+	config, err := imageProviderConfig(nil, providerConfig)
+	if err != nil || config != providerConfig {
+		t.Fatalf("expected the provider config without registry_auth, got %v, %v", config, err)
+	}
+
+	config, err = imageProviderConfig([]interface{}{map[string]interface{}{
+		"address":     "127.0.0.1:15000",
+		"username":    "testuser",
+		"password":    "testpwd",
+		"config_file": "",
+	}}, providerConfig)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if auth := getAuthConfigForImage("127.0.0.1:15000/tftest-service:v1", config.AuthConfigs); auth.Username != "testuser" || auth.Password != "testpwd" {
+		t.Fatalf("expected the credentials of the image, got %+v", auth)
+	}
+	if auth := getAuthConfigForImage("alpine", config.AuthConfigs); auth.Username != "hub" {
+		t.Fatalf("expected the credentials of the provider for other registries, got %+v", auth)
+	}
+	if providerConfig.AuthConfigs.Configs["https://127.0.0.1:15000"].Username != "provider" {
+		t.Fatalf("the credentials of the provider must not be changed")
+	}
+
+	// pulls with other credentials for the registry don't share their result
+	image := "127.0.0.1:15000/tftest-service:v1"
+	if imagePullKey(image, "", config.AuthConfigs) == imagePullKey(image, "", providerConfig.AuthConfigs) {
+		t.Fatalf("expected pulls with different credentials to have different keys")
+	}
+	if imagePullKey("alpine", "", config.AuthConfigs) != imagePullKey("alpine", "", providerConfig.AuthConfigs) {
+		t.Fatalf("expected pulls with the same credentials to have the same key")
+	}
+}
+
+func TestDockerImagePullPolicy(t *testing.T) {
+	registry := newTestRegistry("")
+	defer registry.server.Close()
+	address := strings.TrimPrefix(registry.server.URL, "https://")
+	providerConfig := &ProviderConfig{AuthConfigs: &AuthConfigs{
+		Registries: map[string]registryOptions{normalizeRegistryAddress(address): {InsecureSkipVerify: true}},
+	}}
+
+	digests := map[string]string{}
+	for _, version := range []string{"local", "newer"} {
+		layer := registry.addBlob("tftest/image", []byte(version))
+		digests[version] = registry.addManifest("tftest/image", "", manifestV2MediaType, registryManifest{
+			MediaType: manifestV2MediaType,
+			Config:    layer,
+			Layers:    []registryManifestDescriptor{layer},
+		})
+	}
+	registry.manifests["tftest/image:1.0"] = registry.manifests["tftest/image:"+digests["local"]]
+
+	name := address + "/tftest/image:1.0"
+	repoDigests := []string{address + "/tftest/image@" + digests["local"]}
+	planImage := func(pullPolicy string) *terraform.InstanceDiff {
+		state := &terraform.InstanceState{
+			ID: "sha256:1234" + name,
+			Attributes: map[string]string{
+				"name":           name,
+				"latest":         "sha256:1234",
+				"pull_policy":    pullPolicy,
+				"repo_digests.#": "1",
+				"repo_digests.0": repoDigests[0],
+			},
+		}
+		config := terraform.NewResourceConfigRaw(map[string]interface{}{"name": name, "pull_policy": pullPolicy})
+		diff, err := resourceDockerImage().Diff(state, config, providerConfig)
+		if err != nil {
+			t.Fatalf("unexpected error planning the image with pull policy %s: %s", pullPolicy, err)
+		}
+		return diff
+	}
+
+	if outdated, err := imageIsOutdated(repoDigests, name, providerConfig); err != nil || outdated {
+		t.Fatalf("expected the image to be up to date, got %t: %v", outdated, err)
+	}
+	if diff := planImage("if_newer"); diff.Attributes["latest"] != nil {
+		t.Fatalf("expected no pull while the image is up to date, got %v", diff)
+	}
+	if diff := planImage("always"); diff.Attributes["latest"] != nil {
+		t.Fatalf("expected no pull while the image is up to date, got %v", diff)
+	}
+
+	// a newer image is pushed with the same name
+	registry.manifests["tftest/image:1.0"] = registry.manifests["tftest/image:"+digests["newer"]]
+	if outdated, err := imageIsOutdated(repoDigests, name, providerConfig); err != nil || !outdated {
+		t.Fatalf("expected the image to be outdated, got %t: %v", outdated, err)
+	}
+	for _, pullPolicy := range []string{"always", "if_newer"} {
+		if diff := planImage(pullPolicy); diff.Attributes["latest"] == nil || !diff.Attributes["latest"].NewComputed {
+			t.Fatalf("expected a pull of the newer image with pull policy %s, got %v", pullPolicy, diff)
+		}
+	}
+}
+
 func TestAccDockerImage_sha265(t *testing.T) {
 	resource.Test(t, resource.TestCase{
 		PreCheck:     func() { testAccPreCheck(t) },
@@ -164,6 +359,334 @@ func TestAccDockerImage_sha265(t *testing.T) {
 	})
 }
 
+func TestAccDockerImage_platform(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccDockerImageDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDockerImagePlatformConfig,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestMatchResourceAttr("docker_image.foo", "latest", contentDigestRegexp),
+					testAccDockerImageHasPlatform("docker_image.foo", "linux", "arm64"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccDockerImage_pullError(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccDockerImageDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccDockerImagePullErrorConfig,
+				ExpectError: regexp.MustCompile(`Error pulling 127.0.0.1:15000/tftest-service from registry 127.0.0.1:15000: .*not found`),
+			},
+		},
+	})
+}
+
+func TestAccDockerImage_pullPolicy(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccDockerImageDestroy,
+		Steps: []resource.TestStep{
+			{
+				// an up to date image plans no pull, which the test framework
+				// checks with a second plan after apply
+				Config: testAccDockerImagePullPolicyConfig,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("docker_image.foo", "pull_policy", "if_newer"),
+					resource.TestMatchResourceAttr("docker_image.foo", "latest", contentDigestRegexp),
+				),
+			},
+		},
+	})
+}
+
+func TestAccDockerImage_build(t *testing.T) {
+	wd, _ := os.Getwd()
+	contextDir := wd + "/../scripts/testing/docker_image_build"
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccDockerImageDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(testAccDockerImageBuildConfig, contextDir),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestMatchResourceAttr("docker_image.foo", "latest", contentDigestRegexp),
+					testAccDockerImageHasLabel("docker_image.foo", "com.example.built-by", "terraform"),
+					resource.TestMatchResourceAttr("docker_image.foo", "build_context_hash", regexp.MustCompile(`\A[a-f0-9]{64}\z`)),
+				),
+			},
+		},
+	})
+}
+
+func TestAccDockerImage_buildContextChange(t *testing.T) {
+	contextDir := testBuildContext(t, map[string]string{
+		"Dockerfile": "FROM alpine:3.1\nCOPY hello.txt /hello.txt\n",
+		"hello.txt":  "Hello",
+	})
+	defer os.RemoveAll(contextDir)
+
+	var imageID string
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccDockerImageDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(testAccDockerImageBuildContextConfig, contextDir),
+				Check: resource.ComposeTestCheckFunc(
+					func(s *terraform.State) error {
+						imageID = s.RootModule().Resources["docker_image.foo"].Primary.Attributes["latest"]
+						return nil
+					},
+				),
+			},
+			{
+				PreConfig: func() {
+					if err := ioutil.WriteFile(filepath.Join(contextDir, "hello.txt"), []byte("Hello again"), 0644); err != nil {
+						t.Fatal(err)
+					}
+				},
+				Config: fmt.Sprintf(testAccDockerImageBuildContextConfig, contextDir),
+				Check: resource.ComposeTestCheckFunc(
+					func(s *terraform.State) error {
+						if s.RootModule().Resources["docker_image.foo"].Primary.Attributes["latest"] == imageID {
+							return fmt.Errorf("Image %s was not rebuilt after the build context changed", imageID)
+						}
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+func TestAccDockerImage_buildFailure(t *testing.T) {
+	wd, _ := os.Getwd()
+	contextDir := wd + "/../scripts/testing/docker_image_build"
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccDockerImageDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config:      fmt.Sprintf(testAccDockerImageBuildFailingConfig, contextDir),
+				ExpectError: regexp.MustCompile(`returned a non-zero code: 3`),
+			},
+		},
+	})
+}
+
+func TestParseImageOptions(t *testing.T) {
+	cases := []struct {
+		image    string
+		expected internalPullImageOptions
+	}{
+		{"alpine", internalPullImageOptions{Repository: "alpine"}},
+		{"alpine:3.1", internalPullImageOptions{Repository: "alpine", Tag: "3.1"}},
+		{"127.0.0.1:15000/tftest-service:v1", internalPullImageOptions{Registry: "127.0.0.1:15000", Repository: "127.0.0.1:15000/tftest-service", Tag: "v1"}},
+		{"127.0.0.1:15000/tftest-service", internalPullImageOptions{Registry: "127.0.0.1:15000", Repository: "127.0.0.1:15000/tftest-service"}},
+		{"stocard/gotthard@sha256:ed752380c07940c651b46c97ca2101034b3be112f4d86198900aa6141f37fe7b", internalPullImageOptions{Repository: "stocard/gotthard", Tag: "sha256:ed752380c07940c651b46c97ca2101034b3be112f4d86198900aa6141f37fe7b"}},
+		{"127.0.0.1:15000/tftest-service:v1@sha256:1234", internalPullImageOptions{Registry: "127.0.0.1:15000", Repository: "127.0.0.1:15000/tftest-service", Tag: "sha256:1234"}},
+	}
+
+	for _, c := range cases {
+		if pullOpts := parseImageOptions(c.image); pullOpts != c.expected {
+			t.Errorf("expected %s to be parsed to %+v, got %+v", c.image, c.expected, pullOpts)
+		}
+	}
+}
+
+func TestReadImagePullOutput(t *testing.T) {
+	output := `{"status":"Pulling from tftest-service","id":"v1"}
+{"status":"Pulling fs layer","progressDetail":{},"id":"e7c96db7181b"}
+{"status":"Downloading","progressDetail":{"current":1024,"total":2048},"id":"e7c96db7181b"}
+{"status":"Pull complete","progressDetail":{},"id":"e7c96db7181b"}
+{"status":"Digest: sha256:ed752380c07940c651b46c97ca2101034b3be112f4d86198900aa6141f37fe7b"}
+`
+	if err := readImagePullOutput(strings.NewReader(output), "127.0.0.1:15000/tftest-service:v1"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	output = `{"status":"Pulling from tftest-service","id":"v1"}
+{"errorDetail":{"message":"manifest for 127.0.0.1:15000/tftest-service:v9 not found: manifest unknown"},"error":"manifest for 127.0.0.1:15000/tftest-service:v9 not found: manifest unknown"}
+{"errorDetail":{"message":"second error"},"error":"second error"}
+`
+	err := readImagePullOutput(strings.NewReader(output), "127.0.0.1:15000/tftest-service:v9")
+	pullErr, ok := err.(*imagePullError)
+	if !ok {
+		t.Fatalf("expected an *imagePullError, got %#v", err)
+	}
+	expected := imagePullError{
+		Registry:   "127.0.0.1:15000",
+		Repository: "127.0.0.1:15000/tftest-service",
+		Message:    "manifest for 127.0.0.1:15000/tftest-service:v9 not found: manifest unknown",
+	}
+	if *pullErr != expected {
+		t.Fatalf("expected %+v, got %+v", expected, *pullErr)
+	}
+
+	err = readImagePullOutput(strings.NewReader(`{"error":"denied: requested access to the resource is denied"}`), "tftest/missing")
+	if err == nil || err.Error() != "Error pulling tftest/missing from registry registry.hub.docker.com: denied: requested access to the resource is denied" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestBuildDockerImageContextTar(t *testing.T) {
+	contextDir := testBuildContext(t, map[string]string{
+		"Dockerfile":        "FROM scratch",
+		".dockerignore":     "*.log\nvendor\nDockerfile\n",
+		"app.go":            "package main",
+		"debug.log":         "ignored",
+		"vendor/lib.go":     "package lib",
+		"static/index.html": "<html></html>",
+		"static/access.log": "kept, patterns only match at the context root",
+	})
+	defer os.RemoveAll(contextDir)
+
+	buildContext, err := buildDockerImageContextTar(contextDir, "Dockerfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer buildContext.Close()
+
+	var names []string
+	tr := tar.NewReader(buildContext)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, header.Name)
+	}
+	sort.Strings(names)
+
+	expected := []string{".dockerignore", "Dockerfile", "app.go", "static/", "static/access.log", "static/index.html"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected build context %v, got %v", expected, names)
+	}
+}
+
+func TestHashDockerImageBuildContext(t *testing.T) {
+	contextDir := testBuildContext(t, map[string]string{
+		"Dockerfile":    "FROM scratch",
+		".dockerignore": "*.log\n",
+		"app.go":        "package main",
+	})
+	defer os.RemoveAll(contextDir)
+
+	hash, err := hashDockerImageBuildContext(contextDir, "Dockerfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(contextDir, "debug.log"), []byte("ignored"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ignoredHash, err := hashDockerImageBuildContext(contextDir, "Dockerfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ignoredHash != hash {
+		t.Fatalf("expected files excluded by .dockerignore not to change the hash %s, got %s", hash, ignoredHash)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(contextDir, "app.go"), []byte("package app"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	changedHash, err := hashDockerImageBuildContext(contextDir, "Dockerfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changedHash == hash {
+		t.Fatalf("expected a changed file to change the hash %s", hash)
+	}
+}
+
+func testBuildContext(t *testing.T, files map[string]string) string {
+	contextDir, err := ioutil.TempDir("", "tf-docker-build")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, content := range files {
+		path := filepath.Join(contextDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return contextDir
+}
+
+func testAccDockerImageHasPlatform(n, os, architecture string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[n]
+		if !ok {
+			return fmt.Errorf("Not found: %s", n)
+		}
+
+		client := testAccProvider.Meta().(*ProviderConfig).DockerClient
+		image, _, err := client.ImageInspectWithRaw(context.Background(), rs.Primary.Attributes["latest"])
+		if err != nil {
+			return err
+		}
+		if image.Os != os || image.Architecture != architecture {
+			return fmt.Errorf("Expected image %s to be for %s/%s, got %s/%s", rs.Primary.Attributes["latest"], os, architecture, image.Os, image.Architecture)
+		}
+		return nil
+	}
+}
+
+func testAccDockerImageHasLabel(n, key, value string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[n]
+		if !ok {
+			return fmt.Errorf("Not found: %s", n)
+		}
+
+		client := testAccProvider.Meta().(*ProviderConfig).DockerClient
+		image, _, err := client.ImageInspectWithRaw(context.Background(), rs.Primary.Attributes["latest"])
+		if err != nil {
+			return err
+		}
+		if image.Config == nil || image.Config.Labels[key] != value {
+			return fmt.Errorf("Image %s does not have the label %s=%s", rs.Primary.Attributes["latest"], key, value)
+		}
+		return nil
+	}
+}
+
+// testAccCheckImageDaemonArchitecture checks that the image was pulled for the
+// architecture of the Docker daemon the tests run against.
+func testAccCheckImageDaemonArchitecture(name string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		client := testAccProvider.Meta().(*ProviderConfig).DockerClient
+		version, err := client.ServerVersion(context.Background())
+		if err != nil {
+			return fmt.Errorf("Unable to get the version of the Docker daemon: %s", err)
+		}
+		return resource.TestCheckResourceAttr(name, "architecture", version.Arch)(s)
+	}
+}
+
 func testAccDockerImageDestroy(s *terraform.State) error {
 	for _, rs := range s.RootModule().Resources {
 		if rs.Type != "docker_image" {
@@ -179,12 +702,107 @@ func testAccDockerImageDestroy(s *terraform.State) error {
 	return nil
 }
 
+func TestDockerImageDiffUnknownBuildContext(t *testing.T) {
+	missing := filepath.Join(os.TempDir(), "tftest-missing-context")
+	for name, raw := range map[string]map[string]interface{}{
+		"missing build context": {
+			"name":  "tftest:latest",
+			"build": []interface{}{map[string]interface{}{"path": missing}},
+		},
+		"unknown build context": {
+			"name":  "tftest:latest",
+			"build": []interface{}{map[string]interface{}{"path": hcl2shim.UnknownVariableValue}},
+		},
+		"missing source archive": {
+			"name":           "tftest:latest",
+			"source_archive": missing + ".tar",
+		},
+		"unknown source archive": {
+			"name":           "tftest:latest",
+			"source_archive": hcl2shim.UnknownVariableValue,
+		},
+	} {
+		diff, err := resourceDockerImage().Diff(nil, terraform.NewResourceConfigRaw(raw), &ProviderConfig{})
+		if err != nil {
+			t.Fatalf("%s: unexpected error planning the image: %s", name, err)
+		}
+		hashKey := "build_context_hash"
+		if _, ok := raw["source_archive"]; ok {
+			hashKey = "source_archive_hash"
+		}
+		if attr, ok := diff.Attributes[hashKey]; !ok || !attr.NewComputed {
+			t.Fatalf("%s: expected %s to be computed, got %v", name, hashKey, diff)
+		}
+	}
+}
+
+func TestDockerImageDiffRenameBuiltImage(t *testing.T) {
+	contextDir := testBuildContext(t, map[string]string{"Dockerfile": "FROM scratch"})
+	defer os.RemoveAll(contextDir)
+	contextHash, err := hashDockerImageBuildContext(contextDir, "Dockerfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state := &terraform.InstanceState{
+		ID: "sha256:1234tftest:1.0",
+		Attributes: map[string]string{
+			"name":               "tftest:1.0",
+			"latest":             "sha256:1234",
+			"pull_policy":        "missing",
+			"build.#":            "1",
+			"build.0.path":       contextDir,
+			"build.0.dockerfile": "Dockerfile",
+			"build_context_hash": contextHash,
+		},
+	}
+	config := terraform.NewResourceConfigRaw(map[string]interface{}{
+		"name":  "tftest:2.0",
+		"build": []interface{}{map[string]interface{}{"path": contextDir}},
+	})
+
+	diff, err := resourceDockerImage().Diff(state, config, &ProviderConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error planning the image: %s", err)
+	}
+	if !diff.RequiresNew() || !diff.Attributes["name"].RequiresNew {
+		t.Fatalf("expected the renamed image to be built again, got %v", diff)
+	}
+}
+
+func TestAccDockerImage_unsigned(t *testing.T) {
+	registry := "127.0.0.1:15000"
+	image := "127.0.0.1:15000/tftest-service:v1"
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:      fmt.Sprintf(testAccDockerImageSignatureConfig, registry, image, testSignaturePublicKey),
+				ExpectError: regexp.MustCompile(`No signature found for tftest-service@sha256:`),
+			},
+		},
+	})
+}
+
 const testAccDockerImageConfig = `
 resource "docker_image" "foo" {
 	name = "alpine:3.1"
 }
 `
 
+const testAccDockerImageNameMovedConfig = `
+resource "docker_image" "foo" {
+	name = "alpine:3.1"
+	keep_locally = true
+}
+
+resource "docker_image" "bar" {
+	name = "busybox:1.31"
+	keep_locally = true
+}
+`
+
 const testAddDockerPrivateImageConfig = `
 resource "docker_image" "foobar" {
 	name = "gcr.io:443/google_containers/pause:0.8.0"
@@ -223,6 +841,7 @@ provider "docker" {
 	alias = "private"
 	registry_auth {
 		address = "%s"
+		insecure_skip_verify = true
 	}
 }
 data "docker_registry_image" "foo_private" {
@@ -251,8 +870,92 @@ resource "docker_image" "foo_private" {
 }
 `
 
+const testAccDockerImageRegistryAuthConfig = `
+resource "docker_image" "foo_private" {
+	name = "%s"
+	keep_locally = true
+	registry_auth {
+		address = "%s"
+		username = "testuser"
+		password = "testpwd"
+	}
+}
+`
+
 const testAddDockerImageWithSHA256RepoDigest = `
 resource "docker_image" "foobar" {
 	name = "stocard/gotthard@sha256:ed752380c07940c651b46c97ca2101034b3be112f4d86198900aa6141f37fe7b"
 }
 `
+
+const testAccDockerImagePlatformConfig = `
+resource "docker_image" "foo" {
+	name = "alpine:3.10"
+	platform = "linux/arm64"
+}
+`
+
+const testAccDockerImagePullErrorConfig = `
+resource "docker_image" "foo" {
+	name = "127.0.0.1:15000/tftest-service:missing"
+}
+`
+
+const testAccDockerImagePullPolicyConfig = `
+resource "docker_image" "foo" {
+	name = "alpine:3.10"
+	pull_policy = "if_newer"
+}
+`
+
+const testAccDockerImageBuildConfig = `
+resource "docker_image" "foo" {
+	name = "tftest-build:latest"
+	build {
+		path = "%s"
+		target = "final"
+		build_args = {
+			test_arg = "terraform"
+		}
+		labels = {
+			"com.example.built-by" = "terraform"
+		}
+	}
+}
+`
+
+const testAccDockerImageBuildFailingConfig = `
+resource "docker_image" "foo" {
+	name = "tftest-build-failing:latest"
+	build {
+		path = "%s"
+		dockerfile = "Dockerfile.failing"
+	}
+}
+`
+
+const testAccDockerImageBuildContextConfig = `
+resource "docker_image" "foo" {
+	name = "tftest-build-context:latest"
+	build {
+		path = "%s"
+	}
+}
+`
+
+const testAccDockerImageSignatureConfig = `
+provider "docker" {
+	alias = "private"
+	registry_auth {
+		address = "%s"
+		insecure_skip_verify = true
+	}
+}
+resource "docker_image" "foobar" {
+	provider = "docker.private"
+	name = "%s"
+	signature_public_key = <<EOF
+%s
+EOF
+}
+`
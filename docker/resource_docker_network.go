@@ -14,6 +14,9 @@ func resourceDockerNetwork() *schema.Resource {
 		Create: resourceDockerNetworkCreate,
 		Read:   resourceDockerNetworkRead,
 		Delete: resourceDockerNetworkDelete,
+		Importer: &schema.ResourceImporter{
+			State: resourceDockerNetworkImportState,
+		},
 
 		Schema: map[string]*schema.Schema{
 			"name": {
@@ -113,6 +113,29 @@ func resourceDockerNetworkDelete(d *schema.ResourceData, meta interface{}) error
 	return nil
 }
 
+// resourceDockerNetworkImportState looks up the network by its ID or name and
+// sets the attributes which are only passed on creation.
+func resourceDockerNetworkImportState(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	client := meta.(*ProviderConfig).DockerClient
+
+	retNetwork, err := client.NetworkInspect(context.Background(), d.Id(), types.NetworkInspectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("Error inspecting network %s: %s", d.Id(), err)
+	}
+
+	d.SetId(retNetwork.ID)
+	d.Set("name", retNetwork.Name)
+	d.Set("labels", retNetwork.Labels)
+	if retNetwork.IPAM.Driver != "default" {
+		d.Set("ipam_driver", retNetwork.IPAM.Driver)
+	}
+	if err := d.Set("ipam_config", flattenIpamConfigSpec(retNetwork.IPAM.Config)); err != nil {
+		log.Printf("[WARN] failed to set ipam config from API: %s", err)
+	}
+
+	return []*schema.ResourceData{d}, nil
+}
+
 func ipamConfigSetToIpamConfigs(ipamConfigSet *schema.Set) []network.IPAMConfig {
 	ipamConfigs := make([]network.IPAMConfig, ipamConfigSet.Len())
 
@@ -196,3 +219,16 @@ func resourceDockerNetworkRemoveRefreshFunc(
 		return networkID, "removed", nil
 	}
 }
+
+func flattenIpamConfigSpec(in []network.IPAMConfig) *schema.Set {
+	var out = make([]interface{}, len(in), len(in))
+	for i, v := range in {
+		m := make(map[string]interface{})
+		m["subnet"] = v.Subnet
+		m["ip_range"] = v.IPRange
+		m["gateway"] = v.Gateway
+		m["aux_address"] = mapStringStringToMapStringInterface(v.AuxAddress)
+		out[i] = m
+	}
+	return schema.NewSet(resourceDockerIpamConfigHash, out)
+}
@@ -23,6 +23,12 @@ func TestAccDockerNetwork_basic(t *testing.T) {
 					testAccNetwork("docker_network.foo", &n),
 				),
 			},
+			{
+				ResourceName:            "docker_network.foo",
+				ImportState:             true,
+				ImportStateVerify:       true,
+				ImportStateVerifyIgnore: []string{"check_duplicate"},
+			},
 		},
 	})
 }
@@ -0,0 +1,44 @@
+package docker
+
+import (
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceDockerRegistryImage() *schema.Resource {
+	return &schema.Resource{
+		Create:        resourceDockerRegistryImageCreate,
+		Read:          resourceDockerRegistryImageRead,
+		Update:        resourceDockerRegistryImageUpdate,
+		Delete:        resourceDockerRegistryImageDelete,
+		CustomizeDiff: resourceDockerRegistryImageCustomizeDiff,
+
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Type:        schema.TypeString,
+				Description: "Name of the image in the registry, including the registry address and tag",
+				Required:    true,
+				ForceNew:    true,
+			},
+
+			"source_image": {
+				Type:        schema.TypeString,
+				Description: "Name or ID of the local image which is tagged with name before the push",
+				Optional:    true,
+				ForceNew:    true,
+			},
+
+			"keep_remotely": {
+				Type:        schema.TypeBool,
+				Description: "If true, the manifest is not deleted from the registry on destroy",
+				Optional:    true,
+				Default:     false,
+			},
+
+			"sha256_digest": {
+				Type:        schema.TypeString,
+				Description: "Digest of the pushed manifest, which is the one deleted on destroy",
+				Computed:    true,
+			},
+		},
+	}
+}
@@ -0,0 +1,44 @@
+package docker
+
+import (
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceDockerRegistryImageCopy() *schema.Resource {
+	return &schema.Resource{
+		Create:        resourceDockerRegistryImageCopyCreate,
+		Read:          resourceDockerRegistryImageCopyRead,
+		Update:        resourceDockerRegistryImageCopyUpdate,
+		Delete:        resourceDockerRegistryImageCopyDelete,
+		CustomizeDiff: resourceDockerRegistryImageCustomizeDiff,
+
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Type:        schema.TypeString,
+				Description: "Name of the copy in the destination registry, including the registry address and tag",
+				Required:    true,
+				ForceNew:    true,
+			},
+
+			"source_image": {
+				Type:        schema.TypeString,
+				Description: "Name of the image to copy, including the registry address and a tag or digest",
+				Required:    true,
+				ForceNew:    true,
+			},
+
+			"keep_remotely": {
+				Type:        schema.TypeBool,
+				Description: "If true, the manifest is not deleted from the destination registry on destroy",
+				Optional:    true,
+				Default:     false,
+			},
+
+			"sha256_digest": {
+				Type:        schema.TypeString,
+				Description: "Digest of the copied manifest, which is the one deleted on destroy",
+				Computed:    true,
+			},
+		},
+	}
+}
@@ -0,0 +1,357 @@
+package docker
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceDockerRegistryImageCopyCreate(d *schema.ResourceData, meta interface{}) error {
+	name := d.Get("name").(string)
+	sourceImage := d.Get("source_image").(string)
+
+	digest, err := copyRegistryImage(sourceImage, name, meta.(*ProviderConfig))
+	if err != nil {
+		return fmt.Errorf("Unable to copy image %s to %s: %s", sourceImage, name, err)
+	}
+
+	d.SetId(digest)
+	d.Set("sha256_digest", digest)
+
+	return resourceDockerRegistryImageCopyRead(d, meta)
+}
+
+func resourceDockerRegistryImageCopyRead(d *schema.ResourceData, meta interface{}) error {
+	name := d.Get("name").(string)
+	registry, image, err := registryImageCopyDestination(name, meta.(*ProviderConfig))
+	if err != nil {
+		return err
+	}
+
+	// The copy is the manifest with the digest recorded on create, wherever
+	// its tag points to now
+	_, _, _, err = getImageManifest(registry, image, d.Id(), copyableManifestMediaTypes)
+	if err == errRegistryImageNotFound {
+		log.Printf("[WARN] Manifest %s of image %s no longer exists in the registry, removing from state", d.Id(), name)
+		d.SetId("")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Got error when attempting to fetch image version from registry: %s", err)
+	}
+
+	d.Set("sha256_digest", d.Id())
+
+	return nil
+}
+
+func resourceDockerRegistryImageCopyUpdate(d *schema.ResourceData, meta interface{}) error {
+	// only keep_remotely can be updated, which is evaluated on destroy
+	return resourceDockerRegistryImageCopyRead(d, meta)
+}
+
+func resourceDockerRegistryImageCopyDelete(d *schema.ResourceData, meta interface{}) error {
+	if d.Get("keep_remotely").(bool) {
+		return nil
+	}
+
+	name := d.Get("name").(string)
+	registry, image, err := registryImageCopyDestination(name, meta.(*ProviderConfig))
+	if err != nil {
+		return err
+	}
+
+	// The manifests of the platforms of a manifest list may be shared with
+	// other images of the repository, so they are left to the garbage
+	// collection of the registry
+	if err := deleteRegistryImage(registry, image, d.Id()); err != nil {
+		return fmt.Errorf("Unable to delete image %s from the registry: %s", name, err)
+	}
+
+	d.SetId("")
+	return nil
+}
+
+// registryImageCopyDestination returns the connection to the registry of the
+// copy along with the name of its repository
+func registryImageCopyDestination(name string, providerConfig *ProviderConfig) (*registryConnection, string, error) {
+	pullOpts := parseRegistryImageOptions(name)
+	registry, err := newRegistryConnection(pullOpts.Registry, providerConfig)
+	if err != nil {
+		return nil, "", err
+	}
+	return registry, pullOpts.Repository, nil
+}
+
+// copyableManifestMediaTypes are the media types of the manifests which can be
+// copied to another repository. Schema 1 manifests are signed along with the
+// name of their repository and cannot be copied.
+var copyableManifestMediaTypes = []string{manifestListMediaType, ociIndexMediaType, manifestV2MediaType, ociManifestMediaType}
+
+// registryImageCopy copies manifests along with the blobs they reference from
+// a repository to another one, which may be on another registry
+type registryImageCopy struct {
+	source           *registryConnection
+	sourceImage      string
+	destination      *registryConnection
+	destinationImage string
+}
+
+// copyRegistryImage copies the image, a single manifest or a manifest list
+// with the manifests of all its platforms, from its registry to the
+// destination without a Docker daemon. It returns the digest of the manifest
+// on the destination registry.
+func copyRegistryImage(source, destination string, providerConfig *ProviderConfig) (string, error) {
+	sourceOpts := parseRegistryImageOptions(source)
+	sourceRegistry, err := newRegistryConnection(sourceOpts.Registry, providerConfig)
+	if err != nil {
+		return "", err
+	}
+
+	destinationOpts := parseRegistryImageOptions(destination)
+	destinationRegistry, err := newRegistryConnection(destinationOpts.Registry, providerConfig)
+	if err != nil {
+		return "", err
+	}
+
+	imageCopy := &registryImageCopy{
+		source:           sourceRegistry,
+		sourceImage:      sourceOpts.Repository,
+		destination:      destinationRegistry,
+		destinationImage: destinationOpts.Repository,
+	}
+	return imageCopy.copyManifest(sourceOpts.Tag, destinationOpts.Tag)
+}
+
+// copyManifest copies the manifest with the given reference, and the
+// manifests or blobs it references, and pushes it with the destination
+// reference
+func (c *registryImageCopy) copyManifest(reference, destinationReference string) (string, error) {
+	_, mediaType, body, err := getImageManifest(c.source, c.sourceImage, reference, copyableManifestMediaTypes)
+	if err != nil {
+		return "", err
+	}
+
+	switch mediaType {
+	case manifestListMediaType, ociIndexMediaType:
+		manifestList := &registryManifestList{}
+		if err := json.Unmarshal(body, manifestList); err != nil {
+			return "", fmt.Errorf("Error parsing manifest list: %s", err)
+		}
+
+		// the manifests of the list have to exist before the list is pushed
+		for _, manifest := range manifestList.Manifests {
+			if _, err := c.copyManifest(manifest.Digest, manifest.Digest); err != nil {
+				return "", err
+			}
+		}
+
+	case manifestV2MediaType, ociManifestMediaType:
+		manifest := &registryManifest{}
+		if err := json.Unmarshal(body, manifest); err != nil {
+			return "", fmt.Errorf("Error parsing manifest: %s", err)
+		}
+
+		blobs := append([]registryManifestDescriptor{manifest.Config}, manifest.Layers...)
+		for _, blob := range blobs {
+			if isForeignLayer(blob.MediaType) {
+				log.Printf("[DEBUG] Not copying foreign layer %s of %s", blob.Digest, c.sourceImage)
+				continue
+			}
+			if err := c.copyBlob(blob); err != nil {
+				return "", fmt.Errorf("Error copying blob %s: %s", blob.Digest, err)
+			}
+		}
+
+	default:
+		return "", fmt.Errorf("Manifests of media type %s cannot be copied", mediaType)
+	}
+
+	return putImageManifest(c.destination, c.destinationImage, destinationReference, mediaType, body)
+}
+
+// copyBlob copies the blob unless the destination repository has it already.
+// Blobs are mounted from the source repository if both are on the same
+// registry, instead of being downloaded and uploaded again.
+func (c *registryImageCopy) copyBlob(blob registryManifestDescriptor) error {
+	exists, err := registryBlobExists(c.destination, c.destinationImage, blob.Digest)
+	if err != nil {
+		return err
+	}
+	if exists {
+		log.Printf("[DEBUG] Blob %s already exists in %s", blob.Digest, c.destinationImage)
+		return nil
+	}
+
+	mountFrom := ""
+	if c.source.baseURL == c.destination.baseURL {
+		mountFrom = c.sourceImage
+	}
+
+	location, err := startRegistryBlobUpload(c.destination, c.destinationImage, blob.Digest, mountFrom)
+	if err != nil {
+		return err
+	}
+	if location == "" {
+		log.Printf("[DEBUG] Mounted blob %s from %s into %s", blob.Digest, c.sourceImage, c.destinationImage)
+		return nil
+	}
+
+	log.Printf("[DEBUG] Copying blob %s (%d bytes) from %s to %s", blob.Digest, blob.Size, c.sourceImage, c.destinationImage)
+	return uploadRegistryBlob(c.destination, location, blob, func() (io.ReadCloser, error) {
+		return openRegistryBlob(c.source, c.sourceImage, blob.Digest)
+	})
+}
+
+// isForeignLayer reports whether the layer is not distributed by registries,
+// like the base layers of Windows images, so that it is not copied
+func isForeignLayer(mediaType string) bool {
+	return strings.Contains(mediaType, ".foreign.") || strings.Contains(mediaType, ".nondistributable.")
+}
+
+// registryBlobExists reports whether the repository has the blob with the
+// given digest
+func registryBlobExists(registry *registryConnection, image, digest string) (bool, error) {
+	req, err := registry.newRequest("HEAD", "/v2/"+image+"/blobs/"+digest)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := registry.do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+
+	case http.StatusNotFound:
+		return false, nil
+
+	case http.StatusUnauthorized:
+		return false, fmt.Errorf("Bad credentials: %s", resp.Status)
+
+	default:
+		return false, fmt.Errorf("Got bad response from registry: %s", resp.Status)
+	}
+}
+
+// startRegistryBlobUpload starts the upload of a blob and returns the
+// location to upload its content to. If a repository to mount the blob from
+// is given and the registry mounts it, the returned location is empty.
+func startRegistryBlobUpload(registry *registryConnection, image, digest, mountFrom string) (string, error) {
+	path := "/v2/" + image + "/blobs/uploads/"
+	if mountFrom != "" {
+		path += "?" + url.Values{"mount": {digest}, "from": {mountFrom}}.Encode()
+	}
+
+	req, err := registry.newRequest("POST", path)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := registry.do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated:
+		return "", nil
+
+	case http.StatusAccepted:
+		location := resp.Header.Get("Location")
+		if location == "" {
+			return "", fmt.Errorf("The registry did not return the location to upload the blob to")
+		}
+		return location, nil
+
+	case http.StatusUnauthorized:
+		return "", fmt.Errorf("Bad credentials: %s", resp.Status)
+
+	default:
+		return "", fmt.Errorf("Got bad response from registry: %s", resp.Status)
+	}
+}
+
+// uploadRegistryBlob uploads the content of the blob to the location of an
+// upload in a single request. The content is opened again if the request has
+// to be sent again.
+func uploadRegistryBlob(registry *registryConnection, location string, blob registryManifestDescriptor, open func() (io.ReadCloser, error)) error {
+	content, err := open()
+	if err != nil {
+		return err
+	}
+
+	req, err := registry.newRequestWithBody("PUT", location, content)
+	if err != nil {
+		content.Close()
+		return err
+	}
+	query := req.URL.Query()
+	query.Set("digest", blob.Digest)
+	req.URL.RawQuery = query.Encode()
+	req.ContentLength = blob.Size
+	req.GetBody = open
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err := registry.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated:
+		return nil
+
+	case http.StatusUnauthorized:
+		return fmt.Errorf("Bad credentials: %s", resp.Status)
+
+	default:
+		return fmt.Errorf("Got bad response from registry: %s", resp.Status)
+	}
+}
+
+// putImageManifest pushes the manifest with the given reference and returns
+// its digest
+func putImageManifest(registry *registryConnection, image, reference, mediaType string, manifest []byte) (string, error) {
+	req, err := registry.newRequestWithBody("PUT", "/v2/"+image+"/manifests/"+reference, bytes.NewReader(manifest))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", mediaType)
+
+	resp, err := registry.do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		if digest := resp.Header.Get("Docker-Content-Digest"); digest != "" {
+			return digest, nil
+		}
+		sum := sha256.Sum256(manifest)
+		return "sha256:" + hex.EncodeToString(sum[:]), nil
+
+	case http.StatusUnauthorized:
+		return "", fmt.Errorf("Bad credentials: %s", resp.Status)
+
+	default:
+		return "", fmt.Errorf("Got bad response from registry: %s", resp.Status)
+	}
+}
@@ -0,0 +1,379 @@
+package docker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestAccDockerRegistryImageCopy_private(t *testing.T) {
+	registry := "127.0.0.1:15000"
+	source := "127.0.0.1:15000/tftest-service:v1"
+	name := "127.0.0.1:15000/tftest-copy:1.0"
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(testAccDockerRegistryImageCopyConfig, registry, name, source, source),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestMatchResourceAttr("docker_registry_image_copy.foo", "sha256_digest", registryDigestRegexp),
+					resource.TestCheckResourceAttrPair("docker_registry_image_copy.foo", "sha256_digest", "data.docker_registry_image.source", "sha256_digest"),
+				),
+			},
+		},
+		CheckDestroy: testAccDockerRegistryImageCopyDestroy,
+	})
+}
+
+// testAccDockerRegistryImageCopyDestroy checks that the copy which is kept
+// remotely was left in place on destroy, along with its source
+func testAccDockerRegistryImageCopyDestroy(s *terraform.State) error {
+	for _, rs := range s.RootModule().Resources {
+		if rs.Type != "docker_registry_image_copy" {
+			continue
+		}
+
+		name := rs.Primary.Attributes["name"]
+		pullOpts := parseRegistryImageOptions(name)
+		providerConfig := &ProviderConfig{AuthConfigs: &AuthConfigs{
+			Configs:    testAccProvider.Meta().(*ProviderConfig).AuthConfigs.Configs,
+			Registries: map[string]registryOptions{normalizeRegistryAddress(pullOpts.Registry): {InsecureSkipVerify: true}},
+		}}
+		registry, err := newRegistryConnection(pullOpts.Registry, providerConfig)
+		if err != nil {
+			return err
+		}
+
+		if _, _, _, err := getImageManifest(registry, pullOpts.Repository, rs.Primary.ID, copyableManifestMediaTypes); err != nil {
+			return fmt.Errorf("Expected the copy %s@%s to be kept remotely, got: %s", name, rs.Primary.ID, err)
+		}
+		if _, _, err := getRegistryImageDigest(rs.Primary.Attributes["source_image"], "", providerConfig); err != nil {
+			return fmt.Errorf("Expected the source image %s to be left in place, got: %s", rs.Primary.Attributes["source_image"], err)
+		}
+	}
+	return nil
+}
+
+// testRegistry is an in-memory registry which serves the blobs and manifests
+// of its repositories, and asks for bearer tokens if a token is set
+type testRegistry struct {
+	mu        sync.Mutex
+	server    *httptest.Server
+	token     string
+	blobs     map[string][]byte
+	manifests map[string]testRegistryManifest
+	uploads   int
+	mounts    int
+}
+
+type testRegistryManifest struct {
+	mediaType string
+	content   []byte
+}
+
+func newTestRegistry(token string) *testRegistry {
+	registry := &testRegistry{
+		token:     token,
+		blobs:     make(map[string][]byte),
+		manifests: make(map[string]testRegistryManifest),
+	}
+	registry.server = httptest.NewTLSServer(http.HandlerFunc(registry.serveHTTP))
+	return registry
+}
+
+func testDigest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func (r *testRegistry) addBlob(repository string, content []byte) registryManifestDescriptor {
+	r.blobs[repository+"@"+testDigest(content)] = content
+	return registryManifestDescriptor{
+		MediaType: "application/vnd.docker.image.rootfs.diff.tar.gzip",
+		Digest:    testDigest(content),
+		Size:      int64(len(content)),
+	}
+}
+
+func (r *testRegistry) addManifest(repository, tag, mediaType string, manifest interface{}) string {
+	content, _ := json.Marshal(manifest)
+	r.manifests[repository+":"+testDigest(content)] = testRegistryManifest{mediaType, content}
+	if tag != "" {
+		r.manifests[repository+":"+tag] = testRegistryManifest{mediaType, content}
+	}
+	return testDigest(content)
+}
+
+func (r *testRegistry) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if req.URL.Path == "/token" {
+		fmt.Fprintf(w, `{"token": "%s"}`, r.token)
+		return
+	}
+	if r.token != "" && req.Header.Get("Authorization") != "Bearer "+r.token {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry",scope="repository:tftest:pull,push"`, r.server.URL))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+	switch {
+	case strings.Contains(path, "/blobs/uploads/"):
+		repository := path[:strings.Index(path, "/blobs/uploads/")]
+		r.serveUpload(w, req, repository)
+
+	case strings.Contains(path, "/blobs/"):
+		index := strings.Index(path, "/blobs/")
+		blob, ok := r.blobs[path[:index]+"@"+path[index+len("/blobs/"):]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(blob)))
+		if req.Method == "GET" {
+			w.Write(blob)
+		}
+
+	case strings.Contains(path, "/manifests/"):
+		index := strings.Index(path, "/manifests/")
+		repository, reference := path[:index], path[index+len("/manifests/"):]
+		switch req.Method {
+		case "PUT":
+			r.servePutManifest(w, req, repository, reference)
+			return
+		case "DELETE":
+			r.serveDeleteManifest(w, repository, reference)
+			return
+		}
+		manifest, ok := r.manifests[repository+":"+reference]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", manifest.mediaType)
+		w.Header().Set("Docker-Content-Digest", testDigest(manifest.content))
+		w.Write(manifest.content)
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (r *testRegistry) serveUpload(w http.ResponseWriter, req *http.Request, repository string) {
+	query := req.URL.Query()
+	switch req.Method {
+	case "POST":
+		if from := query.Get("from"); from != "" {
+			if blob, ok := r.blobs[from+"@"+query.Get("mount")]; ok {
+				r.blobs[repository+"@"+query.Get("mount")] = blob
+				r.mounts++
+				w.WriteHeader(http.StatusCreated)
+				return
+			}
+		}
+		w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/uploads/upload-%d?_state=tftest", repository, r.uploads))
+		w.WriteHeader(http.StatusAccepted)
+
+	case "PUT":
+		content, _ := ioutil.ReadAll(req.Body)
+		if query.Get("_state") != "tftest" || testDigest(content) != query.Get("digest") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		r.blobs[repository+"@"+query.Get("digest")] = content
+		r.uploads++
+		w.WriteHeader(http.StatusCreated)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (r *testRegistry) servePutManifest(w http.ResponseWriter, req *http.Request, repository, reference string) {
+	content, _ := ioutil.ReadAll(req.Body)
+	mediaType := req.Header.Get("Content-Type")
+
+	// like registries, refuse manifests referencing unknown content
+	var references []string
+	switch mediaType {
+	case manifestListMediaType, ociIndexMediaType:
+		manifestList := &registryManifestList{}
+		json.Unmarshal(content, manifestList)
+		for _, manifest := range manifestList.Manifests {
+			if _, ok := r.manifests[repository+":"+manifest.Digest]; !ok {
+				references = append(references, manifest.Digest)
+			}
+		}
+	default:
+		manifest := &registryManifest{}
+		json.Unmarshal(content, manifest)
+		for _, blob := range append([]registryManifestDescriptor{manifest.Config}, manifest.Layers...) {
+			if _, ok := r.blobs[repository+"@"+blob.Digest]; !ok && !isForeignLayer(blob.MediaType) {
+				references = append(references, blob.Digest)
+			}
+		}
+	}
+	if len(references) > 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "unknown references: %v", references)
+		return
+	}
+
+	r.manifests[repository+":"+reference] = testRegistryManifest{mediaType, content}
+	r.manifests[repository+":"+testDigest(content)] = testRegistryManifest{mediaType, content}
+	w.Header().Set("Docker-Content-Digest", testDigest(content))
+	w.WriteHeader(http.StatusCreated)
+}
+
+// serveDeleteManifest deletes the manifest with the given digest along with
+// the tags pointing to it, like registries do
+func (r *testRegistry) serveDeleteManifest(w http.ResponseWriter, repository, digest string) {
+	manifest, ok := r.manifests[repository+":"+digest]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	for reference, other := range r.manifests {
+		if strings.HasPrefix(reference, repository+":") && string(other.content) == string(manifest.content) {
+			delete(r.manifests, reference)
+		}
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func TestCopyRegistryImage(t *testing.T) {
+	source := newTestRegistry("")
+	defer source.server.Close()
+	destination := newTestRegistry("secret-token")
+	defer destination.server.Close()
+
+	config := source.addBlob("tftest/source", []byte(`{"architecture":"amd64","os":"linux"}`))
+	config.MediaType = "application/vnd.docker.container.image.v1+json"
+	amd64Layer := source.addBlob("tftest/source", []byte("amd64 layer"))
+	arm64Layer := source.addBlob("tftest/source", []byte("arm64 layer"))
+	foreignLayer := registryManifestDescriptor{
+		MediaType: "application/vnd.docker.image.rootfs.foreign.diff.tar.gzip",
+		Digest:    testDigest([]byte("foreign layer")),
+		Size:      13,
+	}
+
+	manifests := map[string]string{}
+	for platform, layer := range map[string]registryManifestDescriptor{"amd64": amd64Layer, "arm64": arm64Layer} {
+		manifests[platform] = source.addManifest("tftest/source", "", manifestV2MediaType, registryManifest{
+			MediaType: manifestV2MediaType,
+			Config:    config,
+			Layers:    []registryManifestDescriptor{layer, foreignLayer},
+		})
+	}
+	manifestList := map[string]interface{}{
+		"mediaType": manifestListMediaType,
+		"manifests": []map[string]interface{}{
+			{"mediaType": manifestV2MediaType, "digest": manifests["amd64"], "platform": map[string]string{"os": "linux", "architecture": "amd64"}},
+			{"mediaType": manifestV2MediaType, "digest": manifests["arm64"], "platform": map[string]string{"os": "linux", "architecture": "arm64"}},
+		},
+	}
+	listDigest := source.addManifest("tftest/source", "1.0", manifestListMediaType, manifestList)
+
+	sourceAddress := strings.TrimPrefix(source.server.URL, "https://")
+	destinationAddress := strings.TrimPrefix(destination.server.URL, "https://")
+	providerConfig := &ProviderConfig{AuthConfigs: &AuthConfigs{
+		Configs: map[string]types.AuthConfig{},
+		Registries: map[string]registryOptions{
+			normalizeRegistryAddress(sourceAddress):      {InsecureSkipVerify: true},
+			normalizeRegistryAddress(destinationAddress): {InsecureSkipVerify: true},
+		},
+	}}
+
+	// Without a token cache every request of the destination is challenged
+	// and sent again, bodies included
+	digest, err := copyRegistryImage(sourceAddress+"/tftest/source:1.0", destinationAddress+"/tftest/copy:2.0", providerConfig)
+	if err != nil {
+		t.Fatalf("unexpected error copying the image: %s", err)
+	}
+	if digest != listDigest {
+		t.Errorf("expected the digest of the manifest list %s, got %s", listDigest, digest)
+	}
+	for _, reference := range []string{"2.0", listDigest, manifests["amd64"], manifests["arm64"]} {
+		if _, ok := destination.manifests["tftest/copy:"+reference]; !ok {
+			t.Errorf("expected manifest %s in the destination registry", reference)
+		}
+	}
+	if destination.uploads != 3 || destination.mounts != 0 {
+		t.Errorf("expected the config and both layers to be uploaded once, got %d uploads and %d mounts", destination.uploads, destination.mounts)
+	}
+
+	// Within the same registry, blobs are mounted
+	digest, err = copyRegistryImage(sourceAddress+"/tftest/source@"+manifests["arm64"], sourceAddress+"/tftest/mounted:arm64", providerConfig)
+	if err != nil {
+		t.Fatalf("unexpected error copying the image: %s", err)
+	}
+	if digest != manifests["arm64"] {
+		t.Errorf("expected the digest of the manifest %s, got %s", manifests["arm64"], digest)
+	}
+	if source.uploads != 0 || source.mounts != 2 {
+		t.Errorf("expected the config and the layer to be mounted, got %d uploads and %d mounts", source.uploads, source.mounts)
+	}
+
+	if _, err := copyRegistryImage(sourceAddress+"/tftest/source:missing", destinationAddress+"/tftest/copy:missing", providerConfig); err != errRegistryImageNotFound {
+		t.Errorf("expected the image not to be found, got %v", err)
+	}
+
+	// Only the copied manifest list is deleted, the manifests of its
+	// platforms may be shared with other images, and the source is left in place
+	registry, err := newRegistryConnection(destinationAddress, providerConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := deleteRegistryImage(registry, "tftest/copy", listDigest); err != nil {
+		t.Fatalf("unexpected error deleting the copy: %s", err)
+	}
+	if _, ok := destination.manifests["tftest/copy:"+listDigest]; ok {
+		t.Error("expected the copied manifest list to be deleted")
+	}
+	for platform, digest := range manifests {
+		if _, ok := destination.manifests["tftest/copy:"+digest]; !ok {
+			t.Errorf("expected the copied manifest of platform %s to be left in place", platform)
+		}
+	}
+	if _, ok := source.manifests["tftest/source:1.0"]; !ok {
+		t.Error("expected the source image to be left in place")
+	}
+	if err := deleteRegistryImage(registry, "tftest/copy", listDigest); err != nil {
+		t.Errorf("expected an already deleted copy not to be an error, got %s", err)
+	}
+}
+
+const testAccDockerRegistryImageCopyConfig = `
+provider "docker" {
+	alias = "private"
+	registry_auth {
+		address = "%s"
+		insecure_skip_verify = true
+	}
+}
+resource "docker_registry_image_copy" "foo" {
+	provider = "docker.private"
+	name = "%s"
+	source_image = "%s"
+	keep_remotely = true
+}
+data "docker_registry_image" "source" {
+	provider = "docker.private"
+	name = "%s"
+}
+`
@@ -0,0 +1,159 @@
+package docker
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceDockerRegistryImageCreate(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*ProviderConfig).DockerClient
+	authConfigs := meta.(*ProviderConfig).AuthConfigs
+	name := d.Get("name").(string)
+
+	if sourceImage, ok := d.GetOk("source_image"); ok {
+		if err := client.ImageTag(context.Background(), sourceImage.(string), name); err != nil {
+			return fmt.Errorf("Error tagging image %s as %s: %s", sourceImage.(string), name, err)
+		}
+	}
+
+	digest, err := pushImage(client, authConfigs, name)
+	if err != nil {
+		return fmt.Errorf("Unable to push image %s: %s", name, err)
+	}
+
+	d.SetId(digest)
+	d.Set("sha256_digest", digest)
+
+	return resourceDockerRegistryImageRead(d, meta)
+}
+
+func resourceDockerRegistryImageRead(d *schema.ResourceData, meta interface{}) error {
+	name := d.Get("name").(string)
+	pullOpts := parseRegistryImageOptions(name)
+	registry, err := newRegistryConnection(pullOpts.Registry, meta.(*ProviderConfig))
+	if err != nil {
+		return err
+	}
+
+	// The resource manages the manifest it pushed, which is identified by its
+	// digest. Where the tag points to is compared on plan.
+	accept := []string{manifestListMediaType, ociIndexMediaType, manifestV2MediaType, ociManifestMediaType}
+	_, _, _, err = getImageManifest(registry, pullOpts.Repository, d.Id(), accept)
+	if err == errRegistryImageNotFound {
+		log.Printf("[WARN] Manifest %s of image %s no longer exists in the registry, removing from state", d.Id(), name)
+		d.SetId("")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Got error when attempting to fetch image version from registry: %s", err)
+	}
+
+	d.Set("sha256_digest", d.Id())
+
+	return nil
+}
+
+func resourceDockerRegistryImageUpdate(d *schema.ResourceData, meta interface{}) error {
+	// only keep_remotely can be updated, which is evaluated on destroy
+	return resourceDockerRegistryImageRead(d, meta)
+}
+
+func resourceDockerRegistryImageDelete(d *schema.ResourceData, meta interface{}) error {
+	if d.Get("keep_remotely").(bool) {
+		return nil
+	}
+
+	pullOpts := parseRegistryImageOptions(d.Get("name").(string))
+	registry, err := newRegistryConnection(pullOpts.Registry, meta.(*ProviderConfig))
+	if err != nil {
+		return err
+	}
+
+	// Only the manifest the resource pushed is deleted, even if the tag was
+	// moved to another one in the meantime
+	err = deleteRegistryImage(registry, pullOpts.Repository, d.Id())
+	if err != nil {
+		return fmt.Errorf("Unable to delete image %s from the registry: %s", d.Get("name").(string), err)
+	}
+
+	d.SetId("")
+	return nil
+}
+
+// resourceDockerRegistryImageCustomizeDiff plans to push the image again when
+// its tag no longer points to the manifest the resource pushed, e.g. because
+// another image was pushed with the same name.
+func resourceDockerRegistryImageCustomizeDiff(d *schema.ResourceDiff, meta interface{}) error {
+	if d.Id() == "" {
+		return nil
+	}
+
+	name := d.Get("name").(string)
+	digest, _, err := getRegistryImageDigest(name, "", meta.(*ProviderConfig))
+	if err != nil && err != errRegistryImageNotFound {
+		return fmt.Errorf("Got error when attempting to fetch image version from registry: %s", err)
+	}
+	if digest == d.Id() {
+		return nil
+	}
+
+	log.Printf("[INFO] Tag %s no longer points to manifest %s", name, d.Id())
+	if err := d.SetNewComputed("sha256_digest"); err != nil {
+		return err
+	}
+	return d.ForceNew("sha256_digest")
+}
+
+// pushImage pushes the local image to its registry and returns the digest of
+// the pushed manifest.
+func pushImage(client *client.Client, authConfig *AuthConfigs, image string) (string, error) {
+	encodedJSON, err := json.Marshal(getAuthConfigForImage(image, authConfig))
+	if err != nil {
+		return "", fmt.Errorf("error creating auth config: %s", err)
+	}
+
+	out, err := client.ImagePush(context.Background(), image, types.ImagePushOptions{
+		RegistryAuth: base64.URLEncoding.EncodeToString(encodedJSON),
+	})
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+
+	digest := ""
+	decoder := json.NewDecoder(out)
+	for {
+		var message jsonmessage.JSONMessage
+		if err := decoder.Decode(&message); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return "", fmt.Errorf("error reading push output: %s", err)
+		}
+		if message.Error != nil {
+			return "", fmt.Errorf("%s", message.Error.Message)
+		}
+		if message.Aux != nil {
+			var result types.PushResult
+			if err := json.Unmarshal(*message.Aux, &result); err == nil && result.Digest != "" {
+				digest = result.Digest
+			}
+		}
+		log.Printf("[DEBUG] Pushing image %s: %s %s %s", image, message.ID, message.Status, strings.TrimSpace(message.ProgressMessage))
+	}
+
+	if digest == "" {
+		return "", fmt.Errorf("the Docker daemon did not report the digest of the pushed image")
+	}
+	return digest, nil
+}
@@ -0,0 +1,175 @@
+package docker
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestAccDockerRegistryImageResource_push(t *testing.T) {
+	registry := "127.0.0.1:15000"
+	image := "127.0.0.1:15000/tftest-dockerregistryimage:1.0"
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(testAccDockerRegistryImageResourceConfig, registry, image),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestMatchResourceAttr("docker_registry_image.foo", "sha256_digest", registryDigestRegexp),
+				),
+			},
+		},
+		CheckDestroy: checkAndRemoveImages,
+	})
+}
+
+func TestDeleteRegistryImage(t *testing.T) {
+	var deleted []string
+	server := httptest.NewTLSServer(nil)
+	defer server.Close()
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			if username, password, ok := r.BasicAuth(); !ok || username != "testuser" || password != "testpwd" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if r.URL.Query().Get("scope") != "repository:tftest/image:delete" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			fmt.Fprint(w, `{"token": "secret-token"}`)
+		case r.Method == "DELETE" && strings.HasPrefix(r.URL.Path, "/v2/tftest/image/manifests/"):
+			if r.Header.Get("Authorization") != "Bearer secret-token" {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry",scope="repository:tftest/image:delete"`, server.URL))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			digest := strings.TrimPrefix(r.URL.Path, "/v2/tftest/image/manifests/")
+			if digest != "sha256:1234" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			deleted = append(deleted, digest)
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	})
+	registry := &registryConnection{
+		client:   server.Client(),
+		baseURL:  server.URL,
+		username: "testuser",
+		password: "testpwd",
+	}
+
+	if err := deleteRegistryImage(registry, "tftest/image", "sha256:1234"); err != nil {
+		t.Fatalf("unexpected error deleting the image: %s", err)
+	}
+	if len(deleted) != 1 {
+		t.Fatalf("expected the manifest to be deleted once, got %v", deleted)
+	}
+
+	if err := deleteRegistryImage(registry, "tftest/image", "sha256:5678"); err != nil {
+		t.Fatalf("expected an already deleted manifest not to be an error, got: %s", err)
+	}
+
+	registry.password = "wrong"
+	if err := deleteRegistryImage(registry, "tftest/image", "sha256:1234"); err == nil {
+		t.Fatal("expected an error for invalid credentials")
+	}
+}
+
+func TestDockerRegistryImageTagDrift(t *testing.T) {
+	registry := newTestRegistry("")
+	defer registry.server.Close()
+	address := strings.TrimPrefix(registry.server.URL, "https://")
+	providerConfig := &ProviderConfig{AuthConfigs: &AuthConfigs{
+		Registries: map[string]registryOptions{normalizeRegistryAddress(address): {InsecureSkipVerify: true}},
+	}}
+
+	digests := map[string]string{}
+	for _, version := range []string{"pushed", "other"} {
+		layer := registry.addBlob("tftest/image", []byte(version))
+		digests[version] = registry.addManifest("tftest/image", "", manifestV2MediaType, registryManifest{
+			MediaType: manifestV2MediaType,
+			Config:    layer,
+			Layers:    []registryManifestDescriptor{layer},
+		})
+	}
+	registry.manifests["tftest/image:1.0"] = registry.manifests["tftest/image:"+digests["pushed"]]
+
+	name := address + "/tftest/image:1.0"
+	state := &terraform.InstanceState{
+		ID: digests["pushed"],
+		Attributes: map[string]string{
+			"name":          name,
+			"keep_remotely": "false",
+			"sha256_digest": digests["pushed"],
+		},
+	}
+	config := terraform.NewResourceConfigRaw(map[string]interface{}{"name": name})
+
+	diff, err := resourceDockerRegistryImage().Diff(state, config, providerConfig)
+	if err != nil {
+		t.Fatalf("unexpected error planning the image: %s", err)
+	}
+	if !diff.Empty() {
+		t.Fatalf("expected no changes while the tag points to the pushed manifest, got %v", diff)
+	}
+
+	// another image is pushed with the same name
+	registry.manifests["tftest/image:1.0"] = registry.manifests["tftest/image:"+digests["other"]]
+	diff, err = resourceDockerRegistryImage().Diff(state, config, providerConfig)
+	if err != nil {
+		t.Fatalf("unexpected error planning the image: %s", err)
+	}
+	if !diff.RequiresNew() {
+		t.Fatalf("expected the image to be pushed again after the tag was moved, got %v", diff)
+	}
+
+	// the read keeps the pushed manifest, which is still in the registry
+	d := resourceDockerRegistryImage().Data(state)
+	if err := resourceDockerRegistryImageRead(d, providerConfig); err != nil {
+		t.Fatalf("unexpected error reading the image: %s", err)
+	}
+	if d.Id() != digests["pushed"] || d.Get("sha256_digest").(string) != digests["pushed"] {
+		t.Errorf("expected the pushed manifest %s to be kept, got %s", digests["pushed"], d.Get("sha256_digest"))
+	}
+
+	delete(registry.manifests, "tftest/image:"+digests["pushed"])
+	if err := resourceDockerRegistryImageRead(d, providerConfig); err != nil {
+		t.Fatalf("unexpected error reading the image: %s", err)
+	}
+	if d.Id() != "" {
+		t.Errorf("expected the image to be removed from state once its manifest is gone, got %s", d.Id())
+	}
+}
+
+const testAccDockerRegistryImageResourceConfig = `
+provider "docker" {
+	alias = "private"
+	registry_auth {
+		address = "%s"
+		insecure_skip_verify = true
+	}
+}
+resource "docker_image" "foo" {
+	provider = "docker.private"
+	name = "alpine:3.1"
+	keep_locally = true
+}
+resource "docker_registry_image" "foo" {
+	provider = "docker.private"
+	name = "%s"
+	source_image = "${docker_image.foo.latest}"
+	keep_remotely = true
+}
+`
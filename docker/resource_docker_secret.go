@@ -14,6 +14,9 @@ func resourceDockerSecret() *schema.Resource {
 		Create: resourceDockerSecretCreate,
 		Read:   resourceDockerSecretRead,
 		Delete: resourceDockerSecretDelete,
+		Importer: &schema.ResourceImporter{
+			State: schema.ImportStatePassthrough,
+		},
 
 		Schema: map[string]*schema.Schema{
 			"name": {
@@ -76,6 +79,9 @@ func resourceDockerSecretRead(d *schema.ResourceData, meta interface{}) error {
 		return nil
 	}
 	d.SetId(secret.ID)
+	d.Set("name", secret.Spec.Name)
+	d.Set("labels", secret.Spec.Labels)
+	// The data of a secret is never returned by the API, so it is kept as is
 	return nil
 }
 
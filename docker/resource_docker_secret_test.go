@@ -27,6 +27,12 @@ func TestAccDockerSecret_basic(t *testing.T) {
 					resource.TestCheckResourceAttr("docker_secret.foo", "data", "Ymxhc2RzYmxhYmxhMTI0ZHNkd2VzZA=="),
 				),
 			},
+			{
+				ResourceName:            "docker_secret.foo",
+				ImportState:             true,
+				ImportStateVerify:       true,
+				ImportStateVerifyIgnore: []string{"data"},
+			},
 		},
 	})
 }
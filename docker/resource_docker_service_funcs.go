@@ -205,6 +205,24 @@ func resourceDockerServiceDelete(d *schema.ResourceData, meta interface{}) error
 	return nil
 }
 
+func resourceDockerServiceImportState(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	client := meta.(*ProviderConfig).DockerClient
+
+	// the service can be imported by its id or name
+	apiService, err := fetchDockerService(d.Id(), d.Id(), client)
+	if err != nil {
+		return nil, err
+	}
+	if apiService == nil {
+		return nil, fmt.Errorf("Service %s not found", d.Id())
+	}
+
+	d.SetId(apiService.ID)
+	d.Set("name", apiService.Spec.Name)
+
+	return []*schema.ResourceData{d}, nil
+}
+
 /////////////////
 // Helpers
 /////////////////
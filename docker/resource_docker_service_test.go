@@ -181,6 +181,12 @@ func TestAccDockerService_minimalSpec(t *testing.T) {
 					resource.TestMatchResourceAttr("docker_service.foo", "task_spec.0.container_spec.0.image", regexp.MustCompile(`127.0.0.1:15000/tftest-service:v1@sha256.*`)),
 				),
 			},
+			{
+				ResourceName:            "docker_service.foo",
+				ImportState:             true,
+				ImportStateVerify:       true,
+				ImportStateVerifyIgnore: []string{"auth", "converge_config"},
+			},
 		},
 		CheckDestroy: checkAndRemoveImages,
 	})
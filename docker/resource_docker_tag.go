@@ -0,0 +1,156 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func resourceDockerTag() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceDockerTagCreate,
+		Read:   resourceDockerTagRead,
+		Delete: resourceDockerTagDelete,
+
+		Schema: map[string]*schema.Schema{
+			"source_image": {
+				Type:        schema.TypeString,
+				Description: "Name or ID of the local image to tag",
+				Required:    true,
+				ForceNew:    true,
+			},
+			"target_image": {
+				Type:        schema.TypeString,
+				Description: "Name of the tag to create",
+				Required:    true,
+				ForceNew:    true,
+			},
+			"source_image_id": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+func resourceDockerTagCreate(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*ProviderConfig).DockerClient
+	ctx := context.Background()
+	sourceImage := d.Get("source_image").(string)
+	targetImage := d.Get("target_image").(string)
+
+	image, _, err := client.ImageInspectWithRaw(ctx, sourceImage)
+	if err != nil {
+		return fmt.Errorf("Unable to inspect source image %s: %s", sourceImage, err)
+	}
+
+	if err := client.ImageTag(ctx, image.ID, targetImage); err != nil {
+		return fmt.Errorf("Unable to tag image %s as %s: %s", sourceImage, targetImage, err)
+	}
+
+	d.SetId(image.ID + targetImage)
+	d.Set("source_image_id", image.ID)
+
+	return resourceDockerTagRead(d, meta)
+}
+
+func resourceDockerTagRead(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*ProviderConfig).DockerClient
+	targetImage := d.Get("target_image").(string)
+
+	image, _, err := client.ImageInspectWithRaw(context.Background(), targetImage)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			log.Printf("[WARN] Tag %s no longer exists, removing from state", targetImage)
+			d.SetId("")
+			return nil
+		}
+		return fmt.Errorf("Unable to inspect image %s: %s", targetImage, err)
+	}
+
+	// The tag was moved to another image, or the source now refers to another
+	// image, so it has to be created again
+	if image.ID != d.Get("source_image_id").(string) || !dockerTagSourceIsImage(client, d.Get("source_image").(string), image.ID) {
+		log.Printf("[WARN] Tag %s no longer refers to the source image, removing from state", targetImage)
+		d.SetId("")
+		return nil
+	}
+
+	return nil
+}
+
+func resourceDockerTagDelete(d *schema.ResourceData, meta interface{}) error {
+	client := meta.(*ProviderConfig).DockerClient
+	ctx := context.Background()
+	targetImage := d.Get("target_image").(string)
+
+	image, _, err := client.ImageInspectWithRaw(ctx, targetImage)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			d.SetId("")
+			return nil
+		}
+		return fmt.Errorf("Unable to inspect image %s: %s", targetImage, err)
+	}
+
+	// Removing the last reference to an image deletes the image itself,
+	// which has to be left to the resource managing the image
+	if dockerImageReferenceCount(image, targetImage) <= 1 {
+		log.Printf("[WARN] Tag %s is the last reference to image %s, leaving it in place", targetImage, image.ID)
+		d.SetId("")
+		return nil
+	}
+
+	items, err := client.ImageRemove(ctx, targetImage, types.ImageRemoveOptions{PruneChildren: false})
+	if err != nil {
+		return fmt.Errorf("Unable to remove tag %s: %s", targetImage, err)
+	}
+	log.Printf("[INFO] Removed tag %s: %v", targetImage, items)
+
+	d.SetId("")
+	return nil
+}
+
+// dockerImageReferenceCount returns the number of references which keep the
+// image after the target tag is removed, including the tag itself. Removing
+// the last tag of a repository also removes its digest references, so only
+// the digests of other repositories are counted.
+func dockerImageReferenceCount(image types.ImageInspect, targetImage string) int {
+	targetRepository := familiarRepository(parseImageOptions(targetImage).Repository)
+	count := len(image.RepoTags)
+	for _, repoDigest := range image.RepoDigests {
+		if familiarRepository(parseImageOptions(repoDigest).Repository) != targetRepository {
+			count++
+		}
+	}
+	return count
+}
+
+// familiarRepository returns the repository name as the daemon reports it,
+// without the Docker Hub registry and the library namespace.
+func familiarRepository(repository string) string {
+	for _, prefix := range []string{"docker.io/", "index.docker.io/", "registry-1.docker.io/"} {
+		repository = strings.TrimPrefix(repository, prefix)
+	}
+	if strings.Count(repository, "/") == 1 {
+		repository = strings.TrimPrefix(repository, "library/")
+	}
+	return repository
+}
+
+// dockerTagSourceIsImage reports whether the source image still resolves to
+// the image with the given ID. A source which cannot be inspected anymore is
+// not considered a change.
+func dockerTagSourceIsImage(client *client.Client, sourceImage, imageID string) bool {
+	image, _, err := client.ImageInspectWithRaw(context.Background(), sourceImage)
+	if err != nil {
+		return true
+	}
+	return image.ID == imageID
+}
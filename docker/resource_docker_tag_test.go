@@ -0,0 +1,75 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestAccDockerTag_basic(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccDockerTagDestroy,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDockerTagConfig,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestMatchResourceAttr("docker_tag.foo", "source_image_id", contentDigestRegexp),
+					resource.TestCheckResourceAttrPair("docker_tag.foo", "source_image_id", "docker_image.foo", "latest"),
+				),
+			},
+		},
+	})
+}
+
+func testAccDockerTagDestroy(s *terraform.State) error {
+	client := testAccProvider.Meta().(*ProviderConfig).DockerClient
+	for _, rs := range s.RootModule().Resources {
+		if rs.Type != "docker_tag" {
+			continue
+		}
+
+		if _, _, err := client.ImageInspectWithRaw(context.Background(), rs.Primary.Attributes["target_image"]); err == nil {
+			return fmt.Errorf("Tag %s still exists", rs.Primary.Attributes["target_image"])
+		}
+		if _, _, err := client.ImageInspectWithRaw(context.Background(), rs.Primary.Attributes["source_image_id"]); err != nil {
+			return fmt.Errorf("Image %s was removed together with the tag: %s", rs.Primary.Attributes["source_image_id"], err)
+		}
+	}
+	return nil
+}
+
+const testAccDockerTagConfig = `
+resource "docker_image" "foo" {
+	name = "alpine:3.1"
+	keep_locally = true
+}
+
+resource "docker_tag" "foo" {
+	source_image = "${docker_image.foo.name}"
+	target_image = "tftest-tag:1.0"
+}
+`
+
+func TestDockerImageReferenceCount(t *testing.T) {
+	image := types.ImageInspect{
+		RepoTags:    []string{"tftest-tag:1.0"},
+		RepoDigests: []string{"tftest-tag@sha256:1234"},
+	}
+	if count := dockerImageReferenceCount(image, "tftest-tag:1.0"); count != 1 {
+		t.Fatalf("expected the digest of the tagged repository not to be counted, got %d references", count)
+	}
+	if count := dockerImageReferenceCount(image, "docker.io/library/tftest-tag:1.0"); count != 1 {
+		t.Fatalf("expected the normalized repository to match the digest, got %d references", count)
+	}
+
+	image.RepoDigests = append(image.RepoDigests, "alpine@sha256:1234")
+	if count := dockerImageReferenceCount(image, "tftest-tag:1.0"); count != 2 {
+		t.Fatalf("expected the digest of another repository to be counted, got %d references", count)
+	}
+}
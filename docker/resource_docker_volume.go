@@ -17,6 +17,9 @@ func resourceDockerVolume() *schema.Resource {
 		Create: resourceDockerVolumeCreate,
 		Read:   resourceDockerVolumeRead,
 		Delete: resourceDockerVolumeDelete,
+		Importer: &schema.ResourceImporter{
+			State: schema.ImportStatePassthrough,
+		},
 
 		Schema: map[string]*schema.Schema{
 			"name": {
@@ -97,7 +100,9 @@ func resourceDockerVolumeRead(d *schema.ResourceData, meta interface{}) error {
 	}
 
 	d.Set("name", retVolume.Name)
+	d.Set("labels", retVolume.Labels)
 	d.Set("driver", retVolume.Driver)
+	d.Set("driver_opts", retVolume.Options)
 	d.Set("mountpoint", retVolume.Mountpoint)
 
 	return nil
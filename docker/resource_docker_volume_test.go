@@ -24,6 +24,11 @@ func TestAccDockerVolume_basic(t *testing.T) {
 					resource.TestCheckResourceAttr("docker_volume.foo", "name", "testAccDockerVolume_basic"),
 				),
 			},
+			{
+				ResourceName:      "docker_volume.foo",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
 		},
 	})
 }
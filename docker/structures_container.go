@@ -0,0 +1,399 @@
+package docker
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/go-connections/nat"
+	"github.com/docker/go-units"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+type byPortAndProtocol []string
+
+func (s byPortAndProtocol) Len() int {
+	return len(s)
+}
+func (s byPortAndProtocol) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+func (s byPortAndProtocol) Less(i, j int) bool {
+	iSplit := strings.Split(string(s[i]), "/")
+	iPort, _ := strconv.Atoi(iSplit[0])
+	jSplit := strings.Split(string(s[j]), "/")
+	jPort, _ := strconv.Atoi(jSplit[0])
+	return iPort < jPort
+}
+
+func flattenContainerPorts(in nat.PortMap) []interface{} {
+	var out = make([]interface{}, 0)
+
+	var internalPortKeys []string
+	for portAndProtocolKeys := range in {
+		internalPortKeys = append(internalPortKeys, string(portAndProtocolKeys))
+	}
+	sort.Sort(byPortAndProtocol(internalPortKeys))
+
+	for _, portKey := range internalPortKeys {
+		m := make(map[string]interface{})
+
+		portBindings := in[nat.Port(portKey)]
+		for _, portBinding := range portBindings {
+			portProtocolSplit := strings.Split(string(portKey), "/")
+			convertedInternal, _ := strconv.Atoi(portProtocolSplit[0])
+			convertedExternal, _ := strconv.Atoi(portBinding.HostPort)
+			m["internal"] = convertedInternal
+			m["external"] = convertedExternal
+			m["ip"] = portBinding.HostIP
+			m["protocol"] = portProtocolSplit[1]
+			out = append(out, m)
+		}
+	}
+	return out
+}
+func flattenContainerNetworks(in *types.NetworkSettings) []interface{} {
+	var out = make([]interface{}, 0)
+	if in == nil || in.Networks == nil || len(in.Networks) == 0 {
+		return out
+	}
+
+	networks := in.Networks
+	for networkName, networkData := range networks {
+		m := make(map[string]interface{})
+		m["network_name"] = networkName
+		m["ip_address"] = networkData.IPAddress
+		m["ip_prefix_length"] = networkData.IPPrefixLen
+		m["gateway"] = networkData.Gateway
+		out = append(out, m)
+	}
+	return out
+}
+
+// flattenContainerEnv flattens the env of a container without the variables
+// it inherited from its image. Inherited variables are kept if they are
+// already tracked in the state, e.g. because they were set explicitly.
+func flattenContainerEnv(in []string, imageEnv []string, stateEnv *schema.Set) *schema.Set {
+	inherited := make(map[string]struct{}, len(imageEnv))
+	for _, v := range imageEnv {
+		inherited[v] = struct{}{}
+	}
+
+	var out = make([]interface{}, 0, len(in))
+	for _, v := range in {
+		if _, ok := inherited[v]; ok && !stateEnv.Contains(v) {
+			continue
+		}
+		out = append(out, v)
+	}
+	return schema.NewSet(schema.HashString, out)
+}
+
+// flattenContainerLabels flattens the labels of a container without the ones
+// it inherited from its image, unless they are already tracked in the state.
+func flattenContainerLabels(in map[string]string, imageLabels map[string]string, stateLabels map[string]interface{}) map[string]interface{} {
+	mapped := make(map[string]interface{}, len(in))
+	for k, v := range in {
+		if imageValue, ok := imageLabels[k]; ok && imageValue == v {
+			if _, ok := stateLabels[k]; !ok {
+				continue
+			}
+		}
+		mapped[k] = v
+	}
+	return mapped
+}
+
+// flattenContainerLogOpts returns the options of the logging driver which are
+// in the state. The daemon adds its default options, e.g. max-size, to the
+// options of every container, so options which are not in the state are left out.
+func flattenContainerLogOpts(in map[string]string, stateOpts map[string]interface{}) map[string]interface{} {
+	mapped := make(map[string]interface{}, len(stateOpts))
+	for k, v := range in {
+		if _, ok := stateOpts[k]; ok {
+			mapped[k] = v
+		}
+	}
+	return mapped
+}
+
+// flattenContainerLinks converts the links of the host config, which are in
+// the /name:/container/alias format, to the name:alias format of the links
+// attribute. A link whose alias is the name of the linked container is
+// returned as the name only.
+func flattenContainerLinks(in []string) *schema.Set {
+	out := make([]interface{}, 0, len(in))
+	for _, link := range in {
+		parts := strings.SplitN(link, ":", 2)
+		name := strings.TrimPrefix(parts[0], "/")
+		if len(parts) == 2 {
+			if alias := parts[1][strings.LastIndex(parts[1], "/")+1:]; alias != name {
+				name = name + ":" + alias
+			}
+		}
+		out = append(out, name)
+	}
+	return schema.NewSet(schema.HashString, out)
+}
+
+func flattenContainerMounts(in []mount.Mount) *schema.Set {
+	var out = make([]interface{}, len(in), len(in))
+	for i, v := range in {
+		m := make(map[string]interface{})
+		m["target"] = v.Target
+		m["source"] = v.Source
+		m["type"] = string(v.Type)
+		m["read_only"] = v.ReadOnly
+		m["bind_options"] = make([]interface{}, 0, 0)
+		m["volume_options"] = make([]interface{}, 0, 0)
+		m["tmpfs_options"] = make([]interface{}, 0, 0)
+
+		if v.BindOptions != nil {
+			bindOptionsItem := make(map[string]interface{})
+			bindOptionsItem["propagation"] = string(v.BindOptions.Propagation)
+			m["bind_options"] = []interface{}{bindOptionsItem}
+		}
+
+		if v.VolumeOptions != nil {
+			volumeOptionsItem := make(map[string]interface{})
+			volumeOptionsItem["no_copy"] = v.VolumeOptions.NoCopy
+			volumeOptionsItem["labels"] = mapStringStringToMapStringInterface(v.VolumeOptions.Labels)
+			volumeOptionsItem["driver_name"] = ""
+			volumeOptionsItem["driver_options"] = make(map[string]interface{}, 0)
+			if v.VolumeOptions.DriverConfig != nil {
+				volumeOptionsItem["driver_name"] = v.VolumeOptions.DriverConfig.Name
+				volumeOptionsItem["driver_options"] = mapStringStringToMapStringInterface(v.VolumeOptions.DriverConfig.Options)
+			}
+			m["volume_options"] = []interface{}{volumeOptionsItem}
+		}
+
+		if v.TmpfsOptions != nil {
+			tmpfsOptionsItem := make(map[string]interface{})
+			tmpfsOptionsItem["size_bytes"] = int(v.TmpfsOptions.SizeBytes)
+			tmpfsOptionsItem["mode"] = int(v.TmpfsOptions.Mode.Perm())
+			m["tmpfs_options"] = []interface{}{tmpfsOptionsItem}
+		}
+
+		out[i] = m
+	}
+	mountsResource := resourceDockerContainer().Schema["mounts"].Elem.(*schema.Resource)
+	f := schema.HashResource(mountsResource)
+	return schema.NewSet(f, out)
+}
+
+// flattenContainerVolumes rebuilds the volumes from the binds, the volumes-from
+// containers and the anonymous volumes of a container. Anonymous volumes declared
+// by the image are skipped unless they are already tracked in the state.
+func flattenContainerVolumes(in *container.HostConfig, volumes map[string]struct{}, imageVolumes map[string]struct{}, stateVolumes *schema.Set) *schema.Set {
+	var out = make([]interface{}, 0, 0)
+	newVolume := func() map[string]interface{} {
+		return map[string]interface{}{
+			"from_container": "",
+			"container_path": "",
+			"host_path":      "",
+			"volume_name":    "",
+			"read_only":      false,
+		}
+	}
+
+	boundPaths := make(map[string]struct{})
+	for _, bind := range in.Binds {
+		parts := strings.Split(bind, ":")
+		if len(parts) < 2 {
+			continue
+		}
+		m := newVolume()
+		if strings.HasPrefix(parts[0], "/") {
+			m["host_path"] = parts[0]
+		} else {
+			m["volume_name"] = parts[0]
+		}
+		m["container_path"] = parts[1]
+		if len(parts) > 2 {
+			for _, mode := range strings.Split(parts[2], ",") {
+				if mode == "ro" {
+					m["read_only"] = true
+				}
+			}
+		}
+		boundPaths[parts[1]] = struct{}{}
+		out = append(out, m)
+	}
+
+	for _, fromContainer := range in.VolumesFrom {
+		m := newVolume()
+		m["from_container"] = fromContainer
+		out = append(out, m)
+	}
+
+	stateContainerPaths := make(map[string]struct{})
+	if stateVolumes != nil {
+		for _, v := range stateVolumes.List() {
+			volume := v.(map[string]interface{})
+			if volume["host_path"] == "" && volume["volume_name"] == "" {
+				stateContainerPaths[volume["container_path"].(string)] = struct{}{}
+			}
+		}
+	}
+
+	var containerPaths []string
+	for containerPath := range volumes {
+		if _, ok := boundPaths[containerPath]; ok {
+			continue
+		}
+		if _, ok := imageVolumes[containerPath]; ok {
+			if _, ok := stateContainerPaths[containerPath]; !ok {
+				continue
+			}
+		}
+		containerPaths = append(containerPaths, containerPath)
+	}
+	sort.Strings(containerPaths)
+	for _, containerPath := range containerPaths {
+		m := newVolume()
+		m["container_path"] = containerPath
+		out = append(out, m)
+	}
+
+	volumesResource := resourceDockerContainer().Schema["volumes"].Elem.(*schema.Resource)
+	f := schema.HashResource(volumesResource)
+	return schema.NewSet(f, out)
+}
+
+func flattenContainerUlimits(in []*units.Ulimit) *schema.Set {
+	var out = make([]interface{}, len(in), len(in))
+	for i, v := range in {
+		m := make(map[string]interface{})
+		m["name"] = v.Name
+		m["soft"] = int(v.Soft)
+		m["hard"] = int(v.Hard)
+		out[i] = m
+	}
+	ulimitResource := resourceDockerContainer().Schema["ulimit"].Elem.(*schema.Resource)
+	f := schema.HashResource(ulimitResource)
+	return schema.NewSet(f, out)
+}
+
+// flattenContainerDevices flattens the devices of a container. As the container
+// path and the permissions are defaulted on creation, a device which is
+// equivalent to one in the state keeps the representation of the state.
+func flattenContainerDevices(in []container.DeviceMapping, stateDevices *schema.Set) *schema.Set {
+	var out = make([]interface{}, len(in), len(in))
+	for i, v := range in {
+		m := make(map[string]interface{})
+		m["host_path"] = v.PathOnHost
+		m["container_path"] = v.PathInContainer
+		m["permissions"] = v.CgroupPermissions
+
+		if stateDevices != nil {
+			for _, rawStateDevice := range stateDevices.List() {
+				stateDevice := deviceSetToDockerDevices(schema.NewSet(stateDevices.F, []interface{}{rawStateDevice}))[0]
+				if stateDevice == v {
+					m = rawStateDevice.(map[string]interface{})
+					break
+				}
+			}
+		}
+		out[i] = m
+	}
+	devicesResource := resourceDockerContainer().Schema["devices"].Elem.(*schema.Resource)
+	f := schema.HashResource(devicesResource)
+	return schema.NewSet(f, out)
+}
+
+func flattenContainerCapabilities(capAdd []string, capDrop []string) *schema.Set {
+	var out = make([]interface{}, 0, 1)
+	if len(capAdd) > 0 || len(capDrop) > 0 {
+		m := make(map[string]interface{})
+		m["add"] = newStringSet(schema.HashString, capAdd)
+		m["drop"] = newStringSet(schema.HashString, capDrop)
+		out = append(out, m)
+	}
+	capabilitiesResource := resourceDockerContainer().Schema["capabilities"].Elem.(*schema.Resource)
+	f := schema.HashResource(capabilitiesResource)
+	return schema.NewSet(f, out)
+}
+
+// stringSlicesEqual reports whether both slices hold the same strings in the
+// same order, treating nil and empty slices alike.
+func stringSlicesEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func flattenContainerExtraHosts(in []string) *schema.Set {
+	var out = make([]interface{}, 0, len(in))
+	for _, v := range in {
+		split := strings.SplitN(v, ":", 2)
+		if len(split) != 2 {
+			continue
+		}
+		m := make(map[string]interface{})
+		m["host"] = split[0]
+		m["ip"] = split[1]
+		out = append(out, m)
+	}
+	hostResource := resourceDockerContainer().Schema["host"].Elem.(*schema.Resource)
+	f := schema.HashResource(hostResource)
+	return schema.NewSet(f, out)
+}
+
+// flattenContainerNetworksAdvanced flattens the networks a container is connected
+// to. The network of the network mode is skipped when it is the only one, as the
+// container was then not connected to any additional network on creation.
+func flattenContainerNetworksAdvanced(containerID string, networkMode container.NetworkMode, in map[string]*network.EndpointSettings) *schema.Set {
+	var out = make([]interface{}, 0, len(in))
+	modeNetwork := networkMode.NetworkName()
+	if _, ok := in[modeNetwork]; ok && len(in) == 1 {
+		in = map[string]*network.EndpointSettings{}
+	}
+
+	for networkName, settings := range in {
+		m := make(map[string]interface{})
+		m["name"] = networkName
+		aliases := make([]string, 0)
+		for _, alias := range settings.Aliases {
+			// Docker adds the short container ID as an alias in user-defined networks
+			if !strings.HasPrefix(containerID, alias) {
+				aliases = append(aliases, alias)
+			}
+		}
+		m["aliases"] = newStringSet(schema.HashString, aliases)
+		m["ipv4_address"] = ""
+		m["ipv6_address"] = ""
+		if settings.IPAMConfig != nil {
+			m["ipv4_address"] = settings.IPAMConfig.IPv4Address
+			m["ipv6_address"] = settings.IPAMConfig.IPv6Address
+		}
+		out = append(out, m)
+	}
+	networksAdvancedResource := resourceDockerContainer().Schema["networks_advanced"].Elem.(*schema.Resource)
+	f := schema.HashResource(networksAdvancedResource)
+	return schema.NewSet(f, out)
+}
+
+// flattenContainerMemorySwap converts the swap limit of the host config to
+// the memory_swap attribute. The daemon defaults the limit to twice the
+// memory limit, or to unlimited without one, which reads back as unset
+// unless memory_swap is configured.
+func flattenContainerMemorySwap(in *container.HostConfig, configured int) int64 {
+	swap := in.MemorySwap
+	if configured == 0 && (swap < 0 || (in.Memory > 0 && swap == 2*in.Memory)) {
+		return 0
+	}
+	if swap > 0 {
+		swap = swap / 1024 / 1024
+	}
+	return swap
+}
@@ -0,0 +1,114 @@
+package docker
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func TestFlattenContainerEnv(t *testing.T) {
+	imageEnv := []string{"PATH=/usr/bin", "NGINX_VERSION=1.17"}
+	in := []string{"PATH=/usr/bin", "NGINX_VERSION=1.17", "FOO=bar"}
+
+	env := flattenContainerEnv(in, imageEnv, schema.NewSet(schema.HashString, nil))
+	if env.Len() != 1 || !env.Contains("FOO=bar") {
+		t.Fatalf("env should only contain 'FOO=bar' but is %v", env.List())
+	}
+
+	env = flattenContainerEnv(in, imageEnv, schema.NewSet(schema.HashString, []interface{}{"PATH=/usr/bin"}))
+	if env.Len() != 2 || !env.Contains("PATH=/usr/bin") {
+		t.Fatalf("env should keep 'PATH=/usr/bin' from the state but is %v", env.List())
+	}
+}
+
+func TestFlattenContainerLabels(t *testing.T) {
+	imageLabels := map[string]string{"maintainer": "nginx", "version": "1"}
+	in := map[string]string{"maintainer": "nginx", "version": "2", "env": "prod"}
+
+	labels := flattenContainerLabels(in, imageLabels, map[string]interface{}{})
+	if len(labels) != 2 || labels["version"] != "2" || labels["env"] != "prod" {
+		t.Fatalf("labels should contain 'version' and 'env' but are %v", labels)
+	}
+}
+
+func TestFlattenContainerLogOpts(t *testing.T) {
+	// max-size and max-file are defaults of the daemon
+	in := map[string]string{"tag": "tftest", "max-size": "10m", "max-file": "3"}
+
+	opts := flattenContainerLogOpts(in, map[string]interface{}{"tag": "tftest", "max-file": "5"})
+	if expected := map[string]interface{}{"tag": "tftest", "max-file": "3"}; !reflect.DeepEqual(opts, expected) {
+		t.Fatalf("log opts should be %v but are %v", expected, opts)
+	}
+
+	opts = flattenContainerLogOpts(in, map[string]interface{}{"tag": "tftest", "mode": "non-blocking"})
+	if expected := map[string]interface{}{"tag": "tftest"}; !reflect.DeepEqual(opts, expected) {
+		t.Fatalf("log opts removed from the container should be left out but are %v", opts)
+	}
+}
+
+func TestFlattenContainerLinks(t *testing.T) {
+	links := flattenContainerLinks([]string{"/db:/tf-test/db", "/cache:/tf-test/redis"})
+	if links.Len() != 2 || !links.Contains("db") || !links.Contains("cache:redis") {
+		t.Fatalf("links should be 'db' and 'cache:redis' but are %v", links.List())
+	}
+}
+
+func TestFlattenContainerVolumes(t *testing.T) {
+	hostConfig := &container.HostConfig{
+		Binds:       []string{"/tmp:/data:ro", "vol:/cache:rw"},
+		VolumesFrom: []string{"other"},
+	}
+	volumes := map[string]struct{}{"/data": {}, "/cache": {}, "/anon": {}, "/var/lib/image": {}}
+	imageVolumes := map[string]struct{}{"/var/lib/image": {}}
+	stateVolumes := schema.NewSet(schema.HashResource(resourceDockerContainer().Schema["volumes"].Elem.(*schema.Resource)), nil)
+
+	out := flattenContainerVolumes(hostConfig, volumes, imageVolumes, stateVolumes).List()
+	if len(out) != 4 {
+		t.Fatalf("expected 4 volumes but got %v", out)
+	}
+
+	found := map[string]map[string]interface{}{}
+	for _, v := range out {
+		volume := v.(map[string]interface{})
+		key := volume["container_path"].(string)
+		if key == "" {
+			key = volume["from_container"].(string)
+		}
+		found[key] = volume
+	}
+	if found["/data"]["host_path"] != "/tmp" || found["/data"]["read_only"] != true {
+		t.Fatalf("bind mount of host path was not flattened correctly: %v", found["/data"])
+	}
+	if found["/cache"]["volume_name"] != "vol" || found["/cache"]["read_only"] != false {
+		t.Fatalf("bind mount of named volume was not flattened correctly: %v", found["/cache"])
+	}
+	if _, ok := found["other"]; !ok {
+		t.Fatalf("volumes from container were not flattened: %v", out)
+	}
+	if _, ok := found["/anon"]; !ok {
+		t.Fatalf("anonymous volume was not flattened: %v", out)
+	}
+}
+
+func TestFlattenContainerMemorySwap(t *testing.T) {
+	mb := int64(1024 * 1024)
+	cases := []struct {
+		memory, swap int64
+		configured   int
+		expected     int64
+	}{
+		{memory: 128 * mb, swap: 256 * mb, configured: 0, expected: 0},
+		{memory: 128 * mb, swap: 256 * mb, configured: 256, expected: 256},
+		{memory: 128 * mb, swap: 512 * mb, configured: 0, expected: 512},
+		{memory: 0, swap: -1, configured: 0, expected: 0},
+		{memory: 128 * mb, swap: -1, configured: 512, expected: -1},
+	}
+	for _, c := range cases {
+		swap := flattenContainerMemorySwap(&container.HostConfig{Resources: container.Resources{Memory: c.memory, MemorySwap: c.swap}}, c.configured)
+		if swap != c.expected {
+			t.Errorf("memory_swap of memory %d, swap %d and configured %d should be %d but is %d", c.memory, c.swap, c.configured, c.expected, swap)
+		}
+	}
+}
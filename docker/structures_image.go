@@ -0,0 +1,45 @@
+package docker
+
+import (
+	"sort"
+
+	"github.com/docker/go-connections/nat"
+)
+
+// flattenImageExposedPorts returns the exposed ports of an image ordered by
+// port and protocol.
+func flattenImageExposedPorts(in nat.PortSet) []interface{} {
+	ports := make([]nat.Port, 0, len(in))
+	for port := range in {
+		ports = append(ports, port)
+	}
+	sort.Slice(ports, func(i, j int) bool {
+		if ports[i].Int() != ports[j].Int() {
+			return ports[i].Int() < ports[j].Int()
+		}
+		return ports[i].Proto() < ports[j].Proto()
+	})
+
+	out := make([]interface{}, len(ports))
+	for i, port := range ports {
+		out[i] = map[string]interface{}{
+			"port":     port.Int(),
+			"protocol": port.Proto(),
+		}
+	}
+	return out
+}
+
+// flattenRegistryImageLayers returns the layers of a manifest in their order
+// in the image.
+func flattenRegistryImageLayers(in []registryManifestDescriptor) []interface{} {
+	out := make([]interface{}, len(in))
+	for i, layer := range in {
+		out[i] = map[string]interface{}{
+			"digest":     layer.Digest,
+			"size":       int(layer.Size),
+			"media_type": layer.MediaType,
+		}
+	}
+	return out
+}
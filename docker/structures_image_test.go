@@ -0,0 +1,25 @@
+package docker
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/docker/go-connections/nat"
+)
+
+func TestFlattenImageExposedPorts(t *testing.T) {
+	in := nat.PortSet{
+		"8080/tcp": struct{}{},
+		"53/udp":   struct{}{},
+		"53/tcp":   struct{}{},
+	}
+	expected := []interface{}{
+		map[string]interface{}{"port": 53, "protocol": "tcp"},
+		map[string]interface{}{"port": 53, "protocol": "udp"},
+		map[string]interface{}{"port": 8080, "protocol": "tcp"},
+	}
+
+	if out := flattenImageExposedPorts(in); !reflect.DeepEqual(out, expected) {
+		t.Fatalf("expected exposed ports %v, got %v", expected, out)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/hashicorp/go-version"
 	"github.com/hashicorp/terraform/helper/schema"
 )
 
@@ -30,7 +31,7 @@ func validateIntegerGeqThan(threshold int) schema.SchemaValidateFunc {
 		value := v.(int)
 		if value < threshold {
 			errors = append(errors, fmt.Errorf(
-				"%q cannot be lower than %q", k, threshold))
+				"%q cannot be lower than %d", k, threshold))
 		}
 		return
 	}
@@ -123,6 +124,42 @@ func validateStringIsBase64Encoded() schema.SchemaValidateFunc {
 	}
 }
 
+func validateStringIsRegexp() schema.SchemaValidateFunc {
+	return func(v interface{}, k string) (ws []string, errors []error) {
+		value := v.(string)
+		if _, err := regexp.Compile(value); err != nil {
+			errors = append(errors, fmt.Errorf(
+				"%q is not a valid regular expression: %s", k, err))
+		}
+
+		return
+	}
+}
+
+func validateStringIsVersionConstraint() schema.SchemaValidateFunc {
+	return func(v interface{}, k string) (ws []string, errors []error) {
+		value := v.(string)
+		if _, err := version.NewConstraint(value); err != nil {
+			errors = append(errors, fmt.Errorf(
+				"%q is not a valid version constraint: %s", k, err))
+		}
+
+		return
+	}
+}
+
+func validateStringIsSignaturePublicKey() schema.SchemaValidateFunc {
+	return func(v interface{}, k string) (ws []string, errors []error) {
+		value := v.(string)
+		if _, err := parseSignaturePublicKey(value); err != nil {
+			errors = append(errors, fmt.Errorf(
+				"%q is not a valid public key: %s", k, err))
+		}
+
+		return
+	}
+}
+
 func validateDockerContainerPath(v interface{}, k string) (ws []string, errors []error) {
 
 	value := v.(string)
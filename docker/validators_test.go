@@ -9,7 +9,7 @@ func TestValidateIntegerInRange(t *testing.T) {
 	for _, v := range validIntegers {
 		_, errors := validateIntegerInRange(min, max)(v, "name")
 		if len(errors) != 0 {
-			t.Fatalf("%q should be an integer in range (%d, %d): %q", v, min, max, errors)
+			t.Fatalf("%d should be an integer in range (%d, %d): %q", v, min, max, errors)
 		}
 	}
 
@@ -17,7 +17,7 @@ func TestValidateIntegerInRange(t *testing.T) {
 	for _, v := range invalidIntegers {
 		_, errors := validateIntegerInRange(min, max)(v, "name")
 		if len(errors) == 0 {
-			t.Fatalf("%q should be an integer outside range (%d, %d)", v, min, max)
+			t.Fatalf("%d should be an integer outside range (%d, %d)", v, min, max)
 		}
 	}
 }
@@ -25,12 +25,12 @@ func TestValidateIntegerInRange(t *testing.T) {
 func TestValidateIntegerGeqThan0(t *testing.T) {
 	v := 1
 	if _, error := validateIntegerGeqThan(0)(v, "name"); error != nil {
-		t.Fatalf("%q should be an integer greater than 0", v)
+		t.Fatalf("%d should be an integer greater than 0", v)
 	}
 
 	v = -4
 	if _, error := validateIntegerGeqThan(0)(v, "name"); error == nil {
-		t.Fatalf("%q should be an invalid integer smaller than 0", v)
+		t.Fatalf("%d should be an invalid integer smaller than 0", v)
 	}
 }
 
@@ -125,3 +125,39 @@ func TestValidateStringShouldBeBase64Encoded(t *testing.T) {
 		t.Fatalf("%q should NOT be base64 decodeable", v)
 	}
 }
+
+func TestValidateStringIsRegexp(t *testing.T) {
+	v := `^v1\.4\.[0-9]+$`
+	if _, error := validateStringIsRegexp()(v, "name"); error != nil {
+		t.Fatalf("%q should be a valid regular expression", v)
+	}
+
+	v = `^v1.4.[0-9+$`
+	if _, error := validateStringIsRegexp()(v, "name"); error == nil {
+		t.Fatalf("%q should NOT be a valid regular expression", v)
+	}
+}
+
+func TestValidateStringIsVersionConstraint(t *testing.T) {
+	v := `~> 1.4.0`
+	if _, error := validateStringIsVersionConstraint()(v, "name"); error != nil {
+		t.Fatalf("%q should be a valid version constraint", v)
+	}
+
+	v = `1.4.x`
+	if _, error := validateStringIsVersionConstraint()(v, "name"); error == nil {
+		t.Fatalf("%q should NOT be a valid version constraint", v)
+	}
+}
+
+func TestValidateStringIsSignaturePublicKey(t *testing.T) {
+	v := testSignaturePublicKey
+	if _, error := validateStringIsSignaturePublicKey()(v, "name"); error != nil {
+		t.Fatalf("%q should be a valid public key", v)
+	}
+
+	v = "-----BEGIN PUBLIC KEY-----\nnot a key\n-----END PUBLIC KEY-----"
+	if _, error := validateStringIsSignaturePublicKey()(v, "name"); error == nil {
+		t.Fatalf("%q should NOT be a valid public key", v)
+	}
+}
@@ -10,6 +10,7 @@ require (
 	github.com/docker/go-connections v0.4.0
 	github.com/docker/go-units v0.0.0-20171221200356-d59758554a3d
 	github.com/gorilla/mux v1.7.2 // indirect
+	github.com/hashicorp/go-version v1.1.0
 	github.com/hashicorp/terraform v0.12.8
 	github.com/morikuni/aec v0.0.0-20170113033406-39771216ff4c // indirect
 	github.com/opencontainers/go-digest v1.0.0-rc1 // indirect